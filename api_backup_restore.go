@@ -0,0 +1,428 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version of the JSON backup format, increased whenever the format changes in a way older versions cannot restore
+const backupFormatVersion = 1
+
+// A JSON backup of the whole library
+// Tables holds every row of every table in the DB, keyed by the table name, each row is keyed by its column names
+// Covers holds the cover images, keyed by their file name
+type LibraryBackup struct {
+	FormatVersion int                         `json:"formatVersion"`
+	CreatedAt     string                      `json:"createdAt"`
+	Tables        map[string][]map[string]any `json:"tables"`
+	Covers        map[string][]byte           `json:"covers"`
+}
+
+// Returns a JSON backup of every table in the DB other than the audit log, and the cover images
+// Tables are found from the DB itself, so any table added later is included without changing this
+func exportBackup(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the names of all the tables in the DB
+	tableNames, err := getTableNames(db)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	libraryBackup := LibraryBackup{FormatVersion: backupFormatVersion, CreatedAt: time.Now().UTC().Format(time.RFC3339), Tables: map[string][]map[string]any{},
+		Covers: map[string][]byte{}}
+
+	// Read every row of every table, the table names come from the DB, so they are safe to use in the query
+	for _, tableName := range tableNames {
+		result, err := db.Query(`SELECT * FROM "` + tableName + `";`)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		rows, err := scanRowsToMaps(result)
+		result.Close()
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		libraryBackup.Tables[tableName] = rows
+	}
+
+	// Add the cover images and their thumbnails, a cover which cannot be read is left out
+	for _, coverRow := range libraryBackup.Tables["BOOKCOVERS"] {
+		for _, column := range []string{"FILENAME", "THUMBNAILFILENAME"} {
+			fileName, _ := coverRow[column].(string)
+			if coverImage, err := os.ReadFile(filepath.Join(coversDirectory, filepath.Base(fileName))); err == nil {
+				libraryBackup.Covers[fileName] = coverImage
+			}
+		}
+	}
+
+	// Send the backup as a file download
+	c.Header("Content-Disposition", `attachment; filename="BOOKMANAGEMENT-backup-`+time.Now().Format("02-Jan-2006")+`.json"`)
+	c.JSON(200, libraryBackup)
+
+}
+
+// Defining JSON body for restoreBackup(). It takes an optional Query Parameter mode, which can be empty, the default, or merge.
+type RestoreBackupParameters struct {
+	Mode string `form:"mode"`
+}
+
+// Restores a JSON backup made by exportBackup(), the backup is the request body
+// In empty mode, the library data tables should not have any rows, in merge mode, they can. In both modes, rows whose ID already exists in the DB are skipped
+// IDs are restored as they are in the backup, so all the links between the tables are kept
+// A user from the backup whose username already exists, like the signed-in admin, is merged into the existing user, which keeps its password and role
+func restoreBackup(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RestoreBackupParameters
+	var restoreBackupParameters RestoreBackupParameters
+
+	// Bind to the struct's members. If the mode is not supported, its rejected with 400
+	if c.ShouldBindQuery(&restoreBackupParameters) != nil || (restoreBackupParameters.Mode != "" && restoreBackupParameters.Mode != "empty" && restoreBackupParameters.Mode != "merge") {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, mode should be empty or merge"})
+		return
+	}
+
+	// Read the backup from the request body, numbers are kept as they are, so IDs and dates are not turned into floats
+	var libraryBackup LibraryBackup
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if decoder.Decode(&libraryBackup) != nil || libraryBackup.Tables == nil {
+		c.JSON(400, gin.H{"status": "Incorrect backup, please provide a backup made by exportBackup"})
+		return
+	}
+
+	// A backup made by a newer version of the format cannot be restored
+	if libraryBackup.FormatVersion < 1 || libraryBackup.FormatVersion > backupFormatVersion {
+		c.JSON(400, gin.H{"status": "Backup format version " + strconv.Itoa(libraryBackup.FormatVersion) + " is not supported, the latest supported version is " + strconv.Itoa(backupFormatVersion)})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the names of all the tables in the DB, only these tables are restored
+	tableNames, err := getTableNames(db)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// In empty mode, check that none of the library data tables have any rows, if any of them do, reject with 409
+	// The other tables, like USERS, always have rows, as the signed-in admin is needed to restore a backup
+	if restoreBackupParameters.Mode != "merge" {
+		for _, tableName := range tableNames {
+			if !isLibraryDataTable(tableName) {
+				continue
+			}
+			var rowCount int
+			db.QueryRow(`SELECT COUNT(*) FROM "` + tableName + `";`).Scan(&rowCount)
+			if rowCount > 0 {
+				c.JSON(409, gin.H{"status": "The DB is not empty, table " + tableName + " has rows. Use mode=merge to merge the backup into it"})
+				return
+			}
+		}
+	}
+
+	// All the tables are restored in a single transaction, so a failed restore does not leave a partly restored DB
+	transaction, err := db.Begin()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer transaction.Rollback()
+
+	// Defining a struct to hold the outcome of each table
+	type RestoredTable struct {
+		Table    string `json:"table"`
+		Restored int    `json:"restored"`
+		Skipped  int    `json:"skipped"`
+	}
+	restoredTables := []RestoredTable{}
+	skippedTables := []string{}
+
+	// Restore the tables in the order of their names, so the outcome is listed in the same order every time
+	backupTableNames := []string{}
+	for tableName := range libraryBackup.Tables {
+		backupTableNames = append(backupTableNames, tableName)
+	}
+	sort.Strings(backupTableNames)
+
+	// Users from the backup whose username already exists are merged into the existing users, so their books are restored into the existing libraries
+	mergedUserIDs := getMergedUserIDs(transaction, libraryBackup.Tables["USERS"])
+
+	for _, tableName := range backupTableNames {
+		rows := libraryBackup.Tables[tableName]
+
+		// Tables which are not in this DB, e.g. from a newer version, and the audit log from older backups are skipped
+		tableColumns, keyColumns, err := getTableColumns(transaction, tableName, tableNames)
+		if err != nil {
+			skippedTables = append(skippedTables, tableName)
+			continue
+		}
+
+		restoredTable := RestoredTable{Table: tableName}
+		for _, row := range rows {
+
+			// A merged user is skipped, as the existing user is kept
+			if _, merged := mergedUserIDs[stringValue(row["ID"])]; tableName == "USERS" && merged {
+				restoredTable.Skipped++
+				continue
+			}
+
+			// The links to a merged user are changed to the existing user
+			row = mergeUserIDs(row, mergedUserIDs)
+
+			// A row whose key already exists in the DB is skipped
+			if len(keyColumns) > 0 && checkRowExists(transaction, tableName, keyColumns, row) {
+				restoredTable.Skipped++
+				continue
+			}
+
+			// Insert the row, only the columns which exist in the table are restored, the column names come from the DB, so they are safe to use in the query
+			columns, placeholders, values := []string{}, []string{}, []any{}
+			for _, column := range tableColumns {
+				if value, found := row[column]; found {
+					columns = append(columns, `"`+column+`"`)
+					placeholders = append(placeholders, "$"+strconv.Itoa(len(columns)))
+					values = append(values, convertJSONValue(value))
+				}
+			}
+			if len(columns) == 0 {
+				restoredTable.Skipped++
+				continue
+			}
+			queryToRestoreRow := `INSERT INTO "` + tableName + `" (` + strings.Join(columns, ", ") + `) VALUES (` + strings.Join(placeholders, ", ") + `);`
+			if _, err = transaction.Exec(queryToRestoreRow, values...); err != nil {
+				c.JSON(400, gin.H{"status": "Could not restore a row of table " + tableName + ", " + err.Error()})
+				return
+			}
+			restoredTable.Restored++
+		}
+		restoredTables = append(restoredTables, restoredTable)
+	}
+
+	if err = transaction.Commit(); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// Write the cover images, covers which are already on disk are kept
+	os.MkdirAll(coversDirectory, 0755)
+	for fileName, coverImage := range libraryBackup.Covers {
+		coverPath := filepath.Join(coversDirectory, filepath.Base(fileName))
+		if _, err := os.Stat(coverPath); os.IsNotExist(err) {
+			os.WriteFile(coverPath, coverImage, 0644)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "Backup restored.", "tables": restoredTables, "skippedTables": skippedTables})
+
+}
+
+// Checks if a table holds library data, the books, their details and the genres, rather than users and their access
+// Returns TRUE if yes, or FALSE if not
+func isLibraryDataTable(tableName string) bool {
+
+	return tableName == "GENRES" || strings.HasPrefix(tableName, "BOOK")
+
+}
+
+// Returns the IDs of the users from a backup whose username already exists in the DB with a different ID, mapped to the existing user's ID
+func getMergedUserIDs(transaction *sql.Tx, userRows []map[string]any) map[string]string {
+
+	mergedUserIDs := map[string]string{}
+	for _, userRow := range userRows {
+		var existingUserID string
+		transaction.QueryRow(`SELECT ID FROM USERS WHERE USERNAME = $1;`, stringValue(userRow["USERNAME"])).Scan(&existingUserID)
+		if backupUserID := stringValue(userRow["ID"]); existingUserID != "" && !strings.EqualFold(existingUserID, backupUserID) {
+			mergedUserIDs[backupUserID] = existingUserID
+		}
+	}
+
+	return mergedUserIDs
+
+}
+
+// Returns a copy of a row from a backup with its links to merged users, in the USERID and LIBRARYID columns, changed to the existing users
+func mergeUserIDs(row map[string]any, mergedUserIDs map[string]string) map[string]any {
+
+	if len(mergedUserIDs) == 0 {
+		return row
+	}
+
+	mergedRow := map[string]any{}
+	for column, value := range row {
+		if existingUserID, merged := mergedUserIDs[stringValue(value)]; merged && (column == "USERID" || column == "LIBRARYID") {
+			value = existingUserID
+		}
+		mergedRow[column] = value
+	}
+
+	return mergedRow
+
+}
+
+// Returns a value from a backup as a string, or an empty string if it is not one
+func stringValue(value any) string {
+
+	text, _ := value.(string)
+
+	return text
+
+}
+
+// Returns the names of all the tables in the DB, leaving out SQLite's own tables and AUDITLOG
+// AUDITLOG is only written by addAuditEntries, so it is neither backed up nor restored
+func getTableNames(db *sql.DB) ([]string, error) {
+
+	result, err := db.Query(`SELECT NAME FROM sqlite_master WHERE TYPE = 'table' AND NAME NOT LIKE 'sqlite_%' AND NAME != 'AUDITLOG' ORDER BY NAME;`)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	tableNames := []string{}
+	for result.Next() {
+		var tableName string
+		result.Scan(&tableName)
+		tableNames = append(tableNames, tableName)
+	}
+
+	return tableNames, result.Err()
+
+}
+
+// Returns the columns of a table and the columns which identify a row, its primary key, or its ID column if it does not have one
+// Returns an error if the table is not one of the known tables
+func getTableColumns(transaction *sql.Tx, tableName string, tableNames []string) ([]string, []string, error) {
+
+	knownTable := false
+	for _, knownTableName := range tableNames {
+		knownTable = knownTable || knownTableName == tableName
+	}
+	if !knownTable {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	result, err := transaction.Query(`SELECT NAME, PK FROM pragma_table_info($1);`, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer result.Close()
+
+	columns, keyColumns, hasID := []string{}, []string{}, false
+	for result.Next() {
+		var column string
+		var primaryKey int
+		result.Scan(&column, &primaryKey)
+		columns = append(columns, column)
+		if primaryKey > 0 {
+			keyColumns = append(keyColumns, column)
+		}
+		hasID = hasID || column == "ID"
+	}
+	if len(keyColumns) == 0 && hasID {
+		keyColumns = []string{"ID"}
+	}
+
+	return columns, keyColumns, result.Err()
+
+}
+
+// Checks if a row with the same key as a row from a backup exists in a table
+// Returns TRUE if yes, or FALSE if not
+func checkRowExists(transaction *sql.Tx, tableName string, keyColumns []string, row map[string]any) bool {
+
+	conditions, values := []string{}, []any{}
+	for _, column := range keyColumns {
+		conditions = append(conditions, `"`+column+`" = $`+strconv.Itoa(len(conditions)+1))
+		values = append(values, convertJSONValue(row[column]))
+	}
+
+	var checkResult int
+	transaction.QueryRow(`SELECT 1 FROM "`+tableName+`" WHERE `+strings.Join(conditions, " AND ")+`;`, values...).Scan(&checkResult)
+
+	return checkResult == 1
+
+}
+
+// Scans all the rows of a query result into maps keyed by the column names
+func scanRowsToMaps(result *sql.Rows) ([]map[string]any, error) {
+
+	columns, err := result.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []map[string]any{}
+	for result.Next() {
+		values := make([]any, len(columns))
+		valuePointers := make([]any, len(columns))
+		for index := range values {
+			valuePointers[index] = &values[index]
+		}
+		if err = result.Scan(valuePointers...); err != nil {
+			return nil, err
+		}
+
+		row := map[string]any{}
+		for index, column := range columns {
+			row[column] = values[index]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, result.Err()
+
+}
+
+// Converts a value decoded from JSON into a value which can be saved in the DB
+// Numbers are saved as integers when they are whole numbers, else as floats
+func convertJSONValue(value any) any {
+
+	number, isNumber := value.(json.Number)
+	if !isNumber {
+		return value
+	}
+
+	if integer, err := number.Int64(); err == nil {
+		return integer
+	}
+	float, _ := number.Float64()
+
+	return float
+
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"time"
 
 	_ "modernc.org/sqlite"
 
@@ -41,8 +42,8 @@ func addABook(c *gin.Context) {
 
 	// Check if the Book and the Author exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2;`
-	result := db.QueryRow(queryToCheckExistingBook, sanitizeString(addABookParameters.BookName), sanitizeString(addABookParameters.AuthorName))
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND USERID=$3;`
+	result := db.QueryRow(queryToCheckExistingBook, sanitizeString(addABookParameters.BookName), sanitizeString(addABookParameters.AuthorName), currentUserID(c))
 	var checkResult string
 	result.Scan(&checkResult)
 
@@ -52,23 +53,37 @@ func addABook(c *gin.Context) {
 		c.JSON(403, gin.H{"status": "Book, " + addABookParameters.BookName + " by " + addABookParameters.AuthorName + " already exists"})
 	} else {
 		generatedID := uniqueIDGenerator()
-		queryToAddABook := `INSERT INTO BOOKMANAGEMENT (ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES) Values ($1, $2, $3, $4, $5, $6, $7, $8);`
+		queryToAddABook := `INSERT INTO BOOKMANAGEMENT (ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
 		db.QueryRow(queryToAddABook, generatedID, sanitizeString(addABookParameters.BookName), sanitizeString(addABookParameters.AuthorName),
-			addABookParameters.TotalPages, 0, 0, 0, "")
+			addABookParameters.TotalPages, 0, 0, 0, "", currentUserID(c))
+
+		// Record when the Book was added, for the recently added feed
+		queryToRecordAddition := `INSERT INTO BOOKADDITIONS (BOOKID, DATEADDED) VALUES ($1, $2);`
+		db.Exec(queryToRecordAddition, generatedID, time.Now().Unix())
+
 		c.JSON(200, gin.H{"status": "Book Added", "bookID": generatedID})
 	}
 
 }
 
 // Defining JSON body for updateBookDetails(). It requires 4 JSON key's bookID, book, author and totalPages.
+// The physical copy's details, room, shelf, box, dateAcquired, source, pricePaid and condition are optional, only the supplied ones are updated
 type UpdateBookDetailsParameters struct {
-	BookID     string `json:"bookID" binding:"required"`
-	BookName   string `json:"book" binding:"required"`
-	AuthorName string `json:"author" binding:"required"`
-	TotalPages int    `json:"totalPages" binding:"required"`
+	BookID       string   `json:"bookID" binding:"required"`
+	BookName     string   `json:"book" binding:"required"`
+	AuthorName   string   `json:"author" binding:"required"`
+	TotalPages   int      `json:"totalPages" binding:"required"`
+	Room         *string  `json:"room"`
+	Shelf        *string  `json:"shelf"`
+	Box          *string  `json:"box"`
+	DateAcquired *string  `json:"dateAcquired"`
+	Source       *string  `json:"source"`
+	PricePaid    *float64 `json:"pricePaid"`
+	Condition    *string  `json:"condition"`
 }
 
 // Updates an existing Book's details, Name, Author and Total Pages
+// Also updates the physical copy's location and ownership details, if any of them are supplied
 func updateBookDetails(c *gin.Context) {
 
 	// Variables for DB and Error
@@ -94,8 +109,8 @@ func updateBookDetails(c *gin.Context) {
 
 	// Check if the BookID exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	result := db.QueryRow(queryToCheckExistingBook, updateBookDetailsParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	result := db.QueryRow(queryToCheckExistingBook, updateBookDetailsParameters.BookID, currentUserID(c))
 	var checkResult string
 	result.Scan(&checkResult)
 
@@ -103,20 +118,88 @@ func updateBookDetails(c *gin.Context) {
 	// Else, its rejected with a 404 as there is no book by that ID
 	if len(checkResult) > 0 {
 
+		// Checks if any of the physical copy's details are supplied
+		ownershipDetailsSupplied := updateBookDetailsParameters.Room != nil || updateBookDetailsParameters.Shelf != nil || updateBookDetailsParameters.Box != nil ||
+			updateBookDetailsParameters.DateAcquired != nil || updateBookDetailsParameters.Source != nil || updateBookDetailsParameters.PricePaid != nil ||
+			updateBookDetailsParameters.Condition != nil
+
+		// Checks if the supplied acquisition date is in DD-MMM-YYYY format, an empty date clears it
+		if updateBookDetailsParameters.DateAcquired != nil && *updateBookDetailsParameters.DateAcquired != "" && !checkDateFormat(*updateBookDetailsParameters.DateAcquired) {
+			c.JSON(400, gin.H{"status": "Incorrect Date format, Date should be in DD-MMM-YYYY format, e.g., 27-Aug-2024"})
+			return
+		}
+
+		// If the supplied price is negative, reject with 400
+		if updateBookDetailsParameters.PricePaid != nil && *updateBookDetailsParameters.PricePaid < 0 {
+			c.JSON(400, gin.H{"status": "Price paid cannot be negative."})
+			return
+		}
+
 		// Check if the update book details match any exisiting book details in the DB
-		// If yes, reject with 403
-		queryToCheckIfBookExists := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND TOTALPAGES=$3;`
-		resultToCheckIfBookExists := db.QueryRow(queryToCheckIfBookExists, sanitizeString(updateBookDetailsParameters.BookName), sanitizeString(updateBookDetailsParameters.AuthorName), updateBookDetailsParameters.TotalPages)
+		// If yes, reject with 403, unless it is the same book and only its physical copy's details are being updated
+		queryToCheckIfBookExists := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND TOTALPAGES=$3 AND USERID=$4;`
+		resultToCheckIfBookExists := db.QueryRow(queryToCheckIfBookExists, sanitizeString(updateBookDetailsParameters.BookName), sanitizeString(updateBookDetailsParameters.AuthorName), updateBookDetailsParameters.TotalPages, currentUserID(c))
 		var checkIfBookExists string
 		resultToCheckIfBookExists.Scan(&checkIfBookExists)
-		if len(checkIfBookExists) > 0 {
+		if len(checkIfBookExists) > 0 && (checkIfBookExists != checkResult || !ownershipDetailsSupplied) {
 			c.JSON(403, gin.H{"status": "Same Book by the same author with the same page number already exists."})
 			return
 		}
 
+		// The new total pages should still be more than the pages read so far, if not, reject
+		if statusCode, reason := checkTotalPagesChange(db, checkResult, currentUserID(c), sanitizeString(updateBookDetailsParameters.BookName),
+			sanitizeString(updateBookDetailsParameters.AuthorName), updateBookDetailsParameters.TotalPages); reason != "" {
+			c.JSON(statusCode, gin.H{"status": reason})
+			return
+		}
+
+		// The physical copy's details and the book details are updated in a single transaction, so one is never changed without the other
+		transaction, err := db.Begin()
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		defer transaction.Rollback()
+
+		// If any of the physical copy's details are supplied, add a row for the book, if its not present, then update only the supplied details
+		// COALESCE keeps the existing value for the details which are not supplied
+		if ownershipDetailsSupplied {
+
+			// Converting the acquisition date into Epoch time, an empty date is stored as 0
+			var dateAcquired *int
+			if updateBookDetailsParameters.DateAcquired != nil {
+				dateAcquiredInEpoch := 0
+				if *updateBookDetailsParameters.DateAcquired != "" {
+					dateAcquiredInEpoch = convertDateToEpoch(*updateBookDetailsParameters.DateAcquired)
+				}
+				dateAcquired = &dateAcquiredInEpoch
+			}
+
+			queryToAddOwnership := `INSERT OR IGNORE INTO BOOKOWNERSHIP (BOOKID) VALUES ($1);`
+			queryToUpdateOwnership := `UPDATE BOOKOWNERSHIP SET ROOM = COALESCE($1, ROOM), SHELF = COALESCE($2, SHELF), BOX = COALESCE($3, BOX),
+			DATEACQUIRED = COALESCE($4, DATEACQUIRED), SOURCE = COALESCE($5, SOURCE), PRICEPAID = COALESCE($6, PRICEPAID), CONDITION = COALESCE($7, CONDITION) WHERE BOOKID = $8;`
+			_, err = transaction.Exec(queryToAddOwnership, updateBookDetailsParameters.BookID)
+			if err == nil {
+				_, err = transaction.Exec(queryToUpdateOwnership, sanitizeOptionalString(updateBookDetailsParameters.Room), sanitizeOptionalString(updateBookDetailsParameters.Shelf),
+					sanitizeOptionalString(updateBookDetailsParameters.Box), dateAcquired, sanitizeOptionalString(updateBookDetailsParameters.Source),
+					updateBookDetailsParameters.PricePaid, sanitizeOptionalString(updateBookDetailsParameters.Condition), updateBookDetailsParameters.BookID)
+			}
+			if err != nil {
+				c.JSON(500, gin.H{"status": "Could not execute Query"})
+				return
+			}
+		}
+
 		// Then if the update book details are different, update the book details
 		queryToUpdateABook := `UPDATE BOOKMANAGEMENT SET BOOK = $1, AUTHOR = $2, TOTALPAGES =$3 WHERE ID = $4;`
-		db.QueryRow(queryToUpdateABook, sanitizeString(updateBookDetailsParameters.BookName), sanitizeString(updateBookDetailsParameters.AuthorName), updateBookDetailsParameters.TotalPages, updateBookDetailsParameters.BookID)
+		_, err = transaction.Exec(queryToUpdateABook, sanitizeString(updateBookDetailsParameters.BookName), sanitizeString(updateBookDetailsParameters.AuthorName), updateBookDetailsParameters.TotalPages, updateBookDetailsParameters.BookID)
+		if err == nil {
+			err = transaction.Commit()
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
 		c.JSON(200, gin.H{"status": "Book, " + updateBookDetailsParameters.BookID + " updated."})
 
 	} else {
@@ -124,3 +207,26 @@ func updateBookDetails(c *gin.Context) {
 	}
 
 }
+
+// Checks a change to a Book's total pages, used by every endpoint which can change them
+// Another Book by the same author with the same name and page number cannot exist, and for a Book being read, the read pages cannot be greater or equal to the total pages
+// Returns the status code and the reason if the change is rejected, or an empty reason if it is allowed
+func checkTotalPagesChange(db *sql.DB, bookID string, userID string, bookName string, authorName string, totalPages int) (int, string) {
+
+	queryToCheckIfBookExists := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND TOTALPAGES=$3 AND USERID=$4 AND ID != $5;`
+	var checkIfBookExists string
+	db.QueryRow(queryToCheckIfBookExists, bookName, authorName, totalPages, userID, bookID).Scan(&checkIfBookExists)
+	if len(checkIfBookExists) > 0 {
+		return 403, "Same Book by the same author with the same page number already exists."
+	}
+
+	queryToGetReadPages := `SELECT READPAGES, DATESTARTED, DATEFINISHED FROM BOOKMANAGEMENT WHERE ID=$1;`
+	var readPages, dateStarted, dateFinished int
+	db.QueryRow(queryToGetReadPages, bookID).Scan(&readPages, &dateStarted, &dateFinished)
+	if dateStarted > 0 && dateFinished == 0 && readPages >= totalPages {
+		return 400, "Read pages cannot be greater or equal to Total pages."
+	}
+
+	return 0, ""
+
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Directory where the cover images and their thumbnails are stored
+const coversDirectory = "./covers"
+
+// Largest cover image which can be uploaded, 5 MB
+const maxCoverSize = 5 << 20
+
+// Largest cover image which can be decoded, 25 megapixels, as a small file can declare dimensions which need far more memory to decode
+const maxCoverPixels = 25_000_000
+
+// Width of the generated thumbnails, the height is scaled to keep the aspect ratio
+const thumbnailWidth = 200
+
+// Supported cover image types and the file extension they are stored with
+var coverImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// Defining form body for uploadCover(). It requires 1 form field bookID and 1 file, cover.
+type UploadCoverParameters struct {
+	BookID string `form:"bookID" binding:"required"`
+}
+
+// Uploads a cover image for a Book, replacing any existing cover, and generates its thumbnail
+func uploadCover(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, UploadCoverParameters
+	var uploadCoverParameters UploadCoverParameters
+
+	// Bind to the struct's members and get the uploaded file. If any of them are missing, its rejected with 400
+	fileHeader, fileErr := c.FormFile("cover")
+	if c.ShouldBind(&uploadCoverParameters) != nil || fileErr != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// If the file is larger than the allowed size, reject with 413
+	if fileHeader.Size > maxCoverSize {
+		c.JSON(413, gin.H{"status": "Cover image cannot be larger than 5 MB"})
+		return
+	}
+
+	// Read the uploaded file
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"status": "Could not read the cover image"})
+		return
+	}
+	defer file.Close()
+	coverImage, err := io.ReadAll(io.LimitReader(file, maxCoverSize))
+	if err != nil {
+		c.JSON(400, gin.H{"status": "Could not read the cover image"})
+		return
+	}
+
+	// Check that the cover image is a JPEG, PNG or WebP image which can be decoded, if not, reject with 415
+	contentType, fileExtension, decodedImage, coverError := checkCoverImage(coverImage)
+	if coverError != "" {
+		c.JSON(415, gin.H{"status": coverError})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID
+	// Result is scanned into the variable, checkResult
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, uploadCoverParameters.BookID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingBook.Scan(&checkResult)
+
+	// If the length of checkResult is 0, the query returned no result, so there is no book by that ID, reject with 404
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + uploadCoverParameters.BookID + " exists"})
+		return
+	}
+
+	// Save the cover and its thumbnail, replacing any existing cover
+	if err = saveCover(db, checkResult, coverImage, contentType, fileExtension, decodedImage); err != nil {
+		c.JSON(500, gin.H{"status": "Could not save the cover image"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Cover uploaded.", "coverUrl": coverURL(checkResult), "thumbnailUrl": coverURL(checkResult) + "&size=thumbnail"})
+
+}
+
+// Defining JSON body for getCover(). It requires 1 Query Parameter bookID and takes an optional Query Parameter size.
+type GetCoverParameters struct {
+	BookID string `form:"bookID" binding:"required"`
+	Size   string `form:"size"`
+}
+
+// Returns a Book's cover image, or its thumbnail if size is thumbnail
+func getCover(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetCoverParameters
+	var getCoverParameters GetCoverParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.Bind(&getCoverParameters) != nil || (getCoverParameters.Size != "" && getCoverParameters.Size != "thumbnail") {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the cover file names of the user's Book
+	queryToGetCover := `SELECT FILENAME, THUMBNAILFILENAME FROM BOOKCOVERS WHERE BOOKID = $1 AND BOOKID IN (SELECT ID FROM BOOKMANAGEMENT WHERE USERID = $2);`
+	result := db.QueryRow(queryToGetCover, getCoverParameters.BookID, currentUserID(c))
+	var coverFileName, thumbnailFileName string
+	result.Scan(&coverFileName, &thumbnailFileName)
+
+	// If there is no cover, reject with 404
+	if len(coverFileName) == 0 {
+		c.JSON(404, gin.H{"status": "No cover for Book with ID, " + getCoverParameters.BookID + " exists"})
+		return
+	}
+
+	// Serve the thumbnail or the cover
+	if getCoverParameters.Size == "thumbnail" {
+		c.File(filepath.Join(coversDirectory, thumbnailFileName))
+		return
+	}
+	c.File(filepath.Join(coversDirectory, coverFileName))
+
+}
+
+// Returns the URL a Book's cover is served from
+func coverURL(bookID string) string {
+
+	return "/getCover?bookID=" + bookID
+
+}
+
+// Deletes a Book's cover and thumbnail files and its row in BOOKCOVERS, if it has a cover
+// Used when a cover is replaced and when a Book is deleted
+func deleteCoverFiles(db *sql.DB, bookID string) {
+
+	queryToGetCover := `SELECT FILENAME, THUMBNAILFILENAME FROM BOOKCOVERS WHERE BOOKID = $1;`
+	result := db.QueryRow(queryToGetCover, bookID)
+	var coverFileName, thumbnailFileName string
+	if result.Scan(&coverFileName, &thumbnailFileName) != nil {
+		return
+	}
+
+	os.Remove(filepath.Join(coversDirectory, coverFileName))
+	os.Remove(filepath.Join(coversDirectory, thumbnailFileName))
+
+	queryToDeleteCover := `DELETE FROM BOOKCOVERS WHERE BOOKID = $1;`
+	db.Exec(queryToDeleteCover, bookID)
+
+}
+
+// Checks that a cover image is a JPEG, PNG or WebP image and decodes it, the decoded image is needed for the thumbnail
+// The image type is detected from its content rather than trusting the file name
+// Returns the reason as the last value if the image cannot be used as a cover
+func checkCoverImage(coverImage []byte) (string, string, image.Image, string) {
+
+	contentType := http.DetectContentType(coverImage)
+	fileExtension, supportedType := coverImageTypes[contentType]
+	if !supportedType {
+		return "", "", nil, "Cover image should be a JPEG, PNG or WebP image"
+	}
+
+	// The dimensions are read from the header first, so images which are too large are rejected before they are decoded
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(coverImage))
+	if err != nil {
+		return "", "", nil, "Cover image could not be decoded"
+	}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 || imageConfig.Width > maxCoverPixels/imageConfig.Height {
+		return "", "", nil, "Cover image cannot be larger than 25 megapixels"
+	}
+
+	decodedImage, _, err := image.Decode(bytes.NewReader(coverImage))
+	if err != nil {
+		return "", "", nil, "Cover image could not be decoded"
+	}
+
+	return contentType, fileExtension, decodedImage, ""
+
+}
+
+// Saves a Book's cover and its thumbnail and adds it to the DB, the files are named using the Book ID
+// Any existing cover is removed first, as it can have a different file extension
+func saveCover(db *sql.DB, bookID string, coverImage []byte, contentType string, fileExtension string, decodedImage image.Image) error {
+
+	deleteCoverFiles(db, bookID)
+
+	coverFileName := bookID + fileExtension
+	thumbnailFileName := bookID + "_thumbnail.jpg"
+	err := os.MkdirAll(coversDirectory, 0755)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(coversDirectory, coverFileName), coverImage, 0644)
+	}
+	if err == nil {
+		err = saveThumbnail(decodedImage, filepath.Join(coversDirectory, thumbnailFileName))
+	}
+	if err != nil {
+		return err
+	}
+
+	queryToAddCover := `INSERT INTO BOOKCOVERS (BOOKID, FILENAME, THUMBNAILFILENAME, CONTENTTYPE) VALUES ($1, $2, $3, $4);`
+	_, err = db.Exec(queryToAddCover, bookID, coverFileName, thumbnailFileName, contentType)
+
+	return err
+
+}
+
+// Scales an image down to the thumbnail width, keeping its aspect ratio, and saves it as a JPEG
+// Images narrower than the thumbnail width are saved as they are
+func saveThumbnail(sourceImage image.Image, thumbnailPath string) error {
+
+	bounds := sourceImage.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailWidth {
+		height = height * thumbnailWidth / width
+		width = thumbnailWidth
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(thumbnail, thumbnail.Bounds(), sourceImage, bounds, draw.Src, nil)
+
+	thumbnailFile, err := os.Create(thumbnailPath)
+	if err != nil {
+		return err
+	}
+	defer thumbnailFile.Close()
+
+	return jpeg.Encode(thumbnailFile, thumbnail, &jpeg.Options{Quality: 80})
+
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A genre in the genre tree, with its child genres
+type GenreNode struct {
+	GenreID  string      `json:"genreID"`
+	Name     string      `json:"name"`
+	ParentID string      `json:"parentID"`
+	Children []GenreNode `json:"children"`
+}
+
+// Common table expression holding a genre and all its descendants in DESCENDANTS, the genre ID is supplied as $1
+// Queries are appended to it, e.g. queryToGetGenreDescendants + ` SELECT ID FROM DESCENDANTS;`
+const queryToGetGenreDescendants = `WITH RECURSIVE DESCENDANTS(ID) AS (
+	SELECT ID FROM GENRES WHERE ID = $1
+	UNION
+	SELECT GENRES.ID FROM GENRES INNER JOIN DESCENDANTS ON GENRES.PARENTID = DESCENDANTS.ID
+)`
+
+// Defining JSON body for addAGenre(). It requires 1 JSON key name, parentID is optional, without it the genre is added at the top of the tree.
+type AddAGenreParameters struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID string `json:"parentID"`
+}
+
+// Adds a Genre to the library's genre tree
+func addAGenre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, AddAGenreParameters
+	var addAGenreParameters AddAGenreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&addAGenreParameters) != nil || len(sanitizeString(addAGenreParameters.Name)) == 0 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// If a parent is supplied, check if it exists, if not, reject with 404
+	if len(addAGenreParameters.ParentID) > 0 && !checkGenreExists(db, addAGenreParameters.ParentID, currentUserID(c)) {
+		c.JSON(404, gin.H{"status": "No Genre with ID, " + addAGenreParameters.ParentID + " exists"})
+		return
+	}
+
+	// Check if a genre by the same name exists under the same parent, if yes, reject with 403
+	queryToCheckExistingGenre := `SELECT ID FROM GENRES WHERE NAME=$1 AND PARENTID=$2 AND USERID=$3;`
+	resultToCheckExistingGenre := db.QueryRow(queryToCheckExistingGenre, sanitizeString(addAGenreParameters.Name), addAGenreParameters.ParentID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingGenre.Scan(&checkResult)
+	if len(checkResult) > 0 {
+		c.JSON(403, gin.H{"status": "Genre, " + sanitizeString(addAGenreParameters.Name) + " already exists"})
+		return
+	}
+
+	// Add the genre
+	generatedID := uniqueIDGenerator()
+	queryToAddAGenre := `INSERT INTO GENRES (ID, NAME, PARENTID, USERID) VALUES ($1, $2, $3, $4);`
+	_, err = db.Exec(queryToAddAGenre, generatedID, sanitizeString(addAGenreParameters.Name), addAGenreParameters.ParentID, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Genre Added", "genreID": generatedID})
+
+}
+
+// Defining JSON body for updateGenre(). It requires 2 JSON key's genreID, name, parentID is optional, without it the genre is moved to the top of the tree.
+type UpdateGenreParameters struct {
+	GenreID  string `json:"genreID" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	ParentID string `json:"parentID"`
+}
+
+// Renames a Genre or moves it under another Genre
+func updateGenre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, UpdateGenreParameters
+	var updateGenreParameters UpdateGenreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&updateGenreParameters) != nil || len(sanitizeString(updateGenreParameters.Name)) == 0 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the genre exists, if not, reject with 404
+	if !checkGenreExists(db, updateGenreParameters.GenreID, currentUserID(c)) {
+		c.JSON(404, gin.H{"status": "No Genre with ID, " + updateGenreParameters.GenreID + " exists"})
+		return
+	}
+
+	// If a parent is supplied, check if it exists, if not, reject with 404
+	if len(updateGenreParameters.ParentID) > 0 && !checkGenreExists(db, updateGenreParameters.ParentID, currentUserID(c)) {
+		c.JSON(404, gin.H{"status": "No Genre with ID, " + updateGenreParameters.ParentID + " exists"})
+		return
+	}
+
+	// A genre cannot be moved under itself or any of its descendants, as that would make a loop in the tree, reject with 400
+	queryToCheckDescendant := queryToGetGenreDescendants + ` SELECT ID FROM DESCENDANTS WHERE ID = $2;`
+	resultToCheckDescendant := db.QueryRow(queryToCheckDescendant, updateGenreParameters.GenreID, updateGenreParameters.ParentID)
+	var checkDescendant string
+	resultToCheckDescendant.Scan(&checkDescendant)
+	if len(checkDescendant) > 0 {
+		c.JSON(400, gin.H{"status": "A Genre cannot be moved under itself or one of its sub genres"})
+		return
+	}
+
+	// Check if another genre by the same name exists under the same parent, if yes, reject with 403
+	queryToCheckExistingGenre := `SELECT ID FROM GENRES WHERE NAME=$1 AND PARENTID=$2 AND ID != $3 AND USERID=$4;`
+	resultToCheckExistingGenre := db.QueryRow(queryToCheckExistingGenre, sanitizeString(updateGenreParameters.Name), updateGenreParameters.ParentID, updateGenreParameters.GenreID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingGenre.Scan(&checkResult)
+	if len(checkResult) > 0 {
+		c.JSON(403, gin.H{"status": "Genre, " + sanitizeString(updateGenreParameters.Name) + " already exists"})
+		return
+	}
+
+	// Update the genre
+	queryToUpdateGenre := `UPDATE GENRES SET NAME = $1, PARENTID = $2 WHERE ID = $3 AND USERID = $4;`
+	_, err = db.Exec(queryToUpdateGenre, sanitizeString(updateGenreParameters.Name), updateGenreParameters.ParentID, updateGenreParameters.GenreID, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Genre, " + updateGenreParameters.GenreID + " updated."})
+
+}
+
+// Defining JSON body for deleteGenre(). It requires 1 Query Parameter genreID.
+type DeleteGenreParameters struct {
+	GenreID string `form:"genreID" binding:"required"`
+}
+
+// Deletes a Genre which has no sub genres, the Books in it are unassigned from it
+func deleteGenre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, DeleteGenreParameters
+	var deleteGenreParameters DeleteGenreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.Bind(&deleteGenreParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the genre exists, if not, reject with 404
+	if !checkGenreExists(db, deleteGenreParameters.GenreID, currentUserID(c)) {
+		c.JSON(404, gin.H{"status": "No Genre by ID, " + deleteGenreParameters.GenreID + " exists."})
+		return
+	}
+
+	// Check if the genre has sub genres, if yes, reject with 403, they have to be moved or deleted first
+	queryToCheckSubGenres := `SELECT ID FROM GENRES WHERE PARENTID = $1 AND USERID = $2;`
+	resultToCheckSubGenres := db.QueryRow(queryToCheckSubGenres, deleteGenreParameters.GenreID, currentUserID(c))
+	var checkSubGenres string
+	resultToCheckSubGenres.Scan(&checkSubGenres)
+	if len(checkSubGenres) > 0 {
+		c.JSON(403, gin.H{"status": "Genre with ID, " + deleteGenreParameters.GenreID + " has sub genres, move or delete them first."})
+		return
+	}
+
+	// Unassign the genre from the library's Books and delete it
+	queryToDeleteBookGenres := `DELETE FROM BOOKGENRES WHERE GENREID = $1 AND BOOKID IN (SELECT ID FROM BOOKMANAGEMENT WHERE USERID = $2 UNION SELECT ID FROM BOOKTRASH WHERE USERID = $2);`
+	queryToDeleteGenre := `DELETE FROM GENRES WHERE ID = $1 AND USERID = $2;`
+	_, err = db.Exec(queryToDeleteBookGenres, deleteGenreParameters.GenreID, currentUserID(c))
+	if err == nil {
+		_, err = db.Exec(queryToDeleteGenre, deleteGenreParameters.GenreID, currentUserID(c))
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Genre with ID, " + deleteGenreParameters.GenreID + " deleted."})
+
+}
+
+// Returns the library's whole genre tree
+func getGenreTree(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get all the genres
+	genres, err := getAllGenres(db, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// Group the genres by their parent, top level genres have an empty parent
+	genresByParent := map[string][]GenreNode{}
+	for _, genre := range genres {
+		genresByParent[genre.ParentID] = append(genresByParent[genre.ParentID], genre)
+	}
+
+	// Returning the tree, starting from the top level genres
+	c.JSON(200, gin.H{"genreTree": buildGenreTree(genresByParent, "")})
+
+}
+
+// Defining JSON body for assignGenre() and unassignGenre(). It requires 2 JSON key's bookID, genreID.
+type AssignGenreParameters struct {
+	BookID  string `json:"bookID" binding:"required"`
+	GenreID string `json:"genreID" binding:"required"`
+}
+
+// Assigns a Genre to a Book, a Book can be in multiple genres
+func assignGenre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, AssignGenreParameters
+	var assignGenreParameters AssignGenreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&assignGenreParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID
+	// Result is scanned into the variable, checkResult
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, assignGenreParameters.BookID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingBook.Scan(&checkResult)
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + assignGenreParameters.BookID + " exists"})
+		return
+	}
+
+	// Check if the genre exists, if not, reject with 404
+	if !checkGenreExists(db, assignGenreParameters.GenreID, currentUserID(c)) {
+		c.JSON(404, gin.H{"status": "No Genre with ID, " + assignGenreParameters.GenreID + " exists"})
+		return
+	}
+
+	// Assign the genre, if its already assigned, reject with 403
+	queryToAssignGenre := `INSERT OR IGNORE INTO BOOKGENRES (BOOKID, GENREID) VALUES ($1, $2);`
+	assigned, err := db.Exec(queryToAssignGenre, assignGenreParameters.BookID, assignGenreParameters.GenreID)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if rowsAdded, _ := assigned.RowsAffected(); rowsAdded == 0 {
+		c.JSON(403, gin.H{"status": "Book, " + assignGenreParameters.BookID + " is already in Genre, " + assignGenreParameters.GenreID})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Book, " + assignGenreParameters.BookID + " added to Genre, " + assignGenreParameters.GenreID + "."})
+
+}
+
+// Unassigns a Genre from a Book
+func unassignGenre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, AssignGenreParameters
+	var assignGenreParameters AssignGenreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&assignGenreParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Unassign the genre from the user's Book, if it was not assigned, reject with 404
+	queryToUnassignGenre := `DELETE FROM BOOKGENRES WHERE BOOKID = $1 AND GENREID = $2 AND BOOKID IN (SELECT ID FROM BOOKMANAGEMENT WHERE USERID = $3);`
+	unassigned, err := db.Exec(queryToUnassignGenre, assignGenreParameters.BookID, assignGenreParameters.GenreID, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if rowsDeleted, _ := unassigned.RowsAffected(); rowsDeleted == 0 {
+		c.JSON(404, gin.H{"status": "Book, " + assignGenreParameters.BookID + " is not in Genre, " + assignGenreParameters.GenreID})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Book, " + assignGenreParameters.BookID + " removed from Genre, " + assignGenreParameters.GenreID + "."})
+
+}
+
+// Defining JSON body for getBooksInGenre(). It requires 1 Query Parameter genreID.
+type GetBooksInGenreParameters struct {
+	GenreID string `form:"genreID" binding:"required"`
+}
+
+// Returns all Books in a Genre, including the Books in all its sub genres
+func getBooksInGenre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetBooksInGenreParameters
+	var getBooksInGenreParameters GetBooksInGenreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.Bind(&getBooksInGenreParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the genre exists, if not, reject with 404
+	if !checkGenreExists(db, getBooksInGenreParameters.GenreID, currentUserID(c)) {
+		c.JSON(404, gin.H{"status": "No Genre by ID, " + getBooksInGenreParameters.GenreID + " exists."})
+		return
+	}
+
+	// Query the DB and result is held into the variable, result
+	// DISTINCT makes sure a Book in more than one of the sub genres is returned once
+	queryToGetBooksInGenre := queryToGetGenreDescendants + ` SELECT DISTINCT BOOKMANAGEMENT.ID, BOOKMANAGEMENT.BOOK, BOOKMANAGEMENT.AUTHOR
+	FROM BOOKGENRES INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKGENRES.BOOKID
+	WHERE BOOKGENRES.GENREID IN (SELECT ID FROM DESCENDANTS) AND BOOKMANAGEMENT.USERID = $2 ORDER BY BOOKMANAGEMENT.BOOK;`
+	result, error := db.Query(queryToGetBooksInGenre, getBooksInGenreParameters.GenreID, currentUserID(c))
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetBookDetails struct {
+		ID     string `json:"id"`
+		Book   string `json:"book"`
+		Author string `json:"author"`
+	}
+
+	// Creating a slice from the struct
+	getBookDetails := []GetBookDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct
+		GetBookDetails := GetBookDetails{}
+
+		// Scan the results into the struct
+		result.Scan(&GetBookDetails.ID, &GetBookDetails.Book, &GetBookDetails.Author)
+
+		// Append to the slice
+		getBookDetails = append(getBookDetails, GetBookDetails)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"booksInGenre": getBookDetails})
+
+}
+
+// Returns the reading statistics of each Genre, every Genre's statistics include the Books in its sub genres
+func getGenreStats(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// GENRETREE pairs every genre with itself and all its descendants
+	// GENREBOOKS then pairs every genre with the distinct Books in it or in its descendants, so a Book is counted once per genre
+	queryToGetGenreStats := `WITH RECURSIVE GENRETREE(ROOTID, ID) AS (
+		SELECT ID, ID FROM GENRES WHERE USERID = $1
+		UNION
+		SELECT GENRETREE.ROOTID, GENRES.ID FROM GENRES INNER JOIN GENRETREE ON GENRES.PARENTID = GENRETREE.ID
+	), GENREBOOKS(ROOTID, BOOKID) AS (
+		SELECT DISTINCT GENRETREE.ROOTID, BOOKGENRES.BOOKID FROM GENRETREE INNER JOIN BOOKGENRES ON BOOKGENRES.GENREID = GENRETREE.ID
+	)
+	SELECT GENRES.ID, GENRES.NAME, COUNT(BOOKMANAGEMENT.ID),
+		COUNT(CASE WHEN BOOKMANAGEMENT.DATESTARTED IS 0 AND BOOKMANAGEMENT.DATEFINISHED IS 0 THEN 1 END),
+		COUNT(CASE WHEN BOOKMANAGEMENT.DATESTARTED IS NOT 0 AND BOOKMANAGEMENT.DATEFINISHED IS 0 THEN 1 END),
+		COUNT(CASE WHEN BOOKMANAGEMENT.DATESTARTED IS NOT 0 AND BOOKMANAGEMENT.DATEFINISHED IS NOT 0 THEN 1 END),
+		TOTAL(BOOKMANAGEMENT.READPAGES)
+	FROM GENRES LEFT JOIN GENREBOOKS ON GENREBOOKS.ROOTID = GENRES.ID LEFT JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = GENREBOOKS.BOOKID
+	AND BOOKMANAGEMENT.USERID = $1
+	WHERE GENRES.USERID = $1
+	GROUP BY GENRES.ID;`
+	result, error := db.Query(queryToGetGenreStats, currentUserID(c))
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetGenreStats struct {
+		GenreID       string `json:"genreID"`
+		Genre         string `json:"genre"`
+		Path          string `json:"path"`
+		TotalBooks    int    `json:"totalBooks"`
+		UnreadBooks   int    `json:"unreadBooks"`
+		ReadingBooks  int    `json:"readingBooks"`
+		FinishedBooks int    `json:"finishedBooks"`
+		PagesRead     int    `json:"pagesRead"`
+	}
+
+	// Creating a slice from the struct
+	getGenreStats := []GetGenreStats{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct
+		GetGenreStats := GetGenreStats{}
+
+		// Scan the results into the struct
+		var pagesRead float64
+		result.Scan(&GetGenreStats.GenreID, &GetGenreStats.Genre, &GetGenreStats.TotalBooks, &GetGenreStats.UnreadBooks, &GetGenreStats.ReadingBooks,
+			&GetGenreStats.FinishedBooks, &pagesRead)
+		GetGenreStats.PagesRead = int(pagesRead)
+
+		// Append to the slice
+		getGenreStats = append(getGenreStats, GetGenreStats)
+	}
+	result.Close()
+
+	// Adding the full path of each genre, e.g. Fiction > Thriller
+	genrePaths, err := getGenrePaths(db, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	for index := range getGenreStats {
+		getGenreStats[index].Path = genrePaths[getGenreStats[index].GenreID]
+	}
+
+	// Sorting by path, so the genres are listed in the order of the tree
+	sort.Slice(getGenreStats, func(i, j int) bool { return getGenreStats[i].Path < getGenreStats[j].Path })
+
+	// Returning all the data
+	c.JSON(200, gin.H{"genreStats": getGenreStats})
+
+}
+
+// Checks if a Genre exists in a user's library
+// Returns TRUE if yes, or FALSE if not
+func checkGenreExists(db *sql.DB, genreID string, userID string) bool {
+
+	queryToCheckExistingGenre := `SELECT ID FROM GENRES WHERE ID=$1 AND USERID=$2;`
+	result := db.QueryRow(queryToCheckExistingGenre, genreID, userID)
+	var checkResult string
+	result.Scan(&checkResult)
+
+	return len(checkResult) > 0
+
+}
+
+// Returns all the Genres in a user's library, sorted by name
+func getAllGenres(db *sql.DB, userID string) ([]GenreNode, error) {
+
+	queryToGetAllGenres := `SELECT ID, NAME, PARENTID FROM GENRES WHERE USERID = $1 ORDER BY NAME;`
+	result, err := db.Query(queryToGetAllGenres, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	genres := []GenreNode{}
+	for result.Next() {
+		genre := GenreNode{Children: []GenreNode{}}
+		result.Scan(&genre.GenreID, &genre.Name, &genre.ParentID)
+		genres = append(genres, genre)
+	}
+
+	return genres, result.Err()
+
+}
+
+// Builds the genre tree under a parent, from the genres grouped by their parent
+func buildGenreTree(genresByParent map[string][]GenreNode, parentID string) []GenreNode {
+
+	genreTree := []GenreNode{}
+	for _, genre := range genresByParent[parentID] {
+		genre.Children = buildGenreTree(genresByParent, genre.GenreID)
+		genreTree = append(genreTree, genre)
+	}
+
+	return genreTree
+
+}
+
+// Returns the full path of every Genre in a user's library, keyed by its ID, e.g. Fiction > Thriller > Techno-thriller
+func getGenrePaths(db *sql.DB, userID string) (map[string]string, error) {
+
+	genres, err := getAllGenres(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the genres by their ID, so the parents can be walked up to the top of the tree
+	genresByID := map[string]GenreNode{}
+	for _, genre := range genres {
+		genresByID[genre.GenreID] = genre
+	}
+
+	genrePaths := map[string]string{}
+	for _, genre := range genres {
+		path := []string{genre.Name}
+		for parent, found := genresByID[genre.ParentID]; found && len(path) <= len(genres); parent, found = genresByID[parent.ParentID] {
+			path = append([]string{parent.Name}, path...)
+		}
+		genrePaths[genre.GenreID] = strings.Join(path, " > ")
+	}
+
+	return genrePaths, nil
+
+}
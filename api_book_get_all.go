@@ -11,6 +11,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Conditions which pick out the unread, reading and finished Books, shared with the OPDS catalog so both list the same Books
+const unreadBooksCondition = `DATESTARTED IS 0 AND DATEFINISHED IS 0`
+const readingBooksCondition = `DATESTARTED IS NOT 0 AND DATEFINISHED IS 0`
+const finishedBooksCondition = `DATESTARTED IS NOT 0 AND DATEFINISHED IS NOT 0`
+
+// Query to get the Books with all their details and their cover, Books without a cover get an empty COVERBOOKID, and NOTES which are NULL are returned as empty
+// A WHERE clause can be added to it to pick out some of the Books
+const queryToGetBooksWithCovers = `SELECT BOOKMANAGEMENT.ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, COALESCE(NOTES, ''), COALESCE(BOOKCOVERS.BOOKID, '') AS COVERBOOKID
+	FROM BOOKMANAGEMENT LEFT JOIN BOOKCOVERS ON BOOKCOVERS.BOOKID = BOOKMANAGEMENT.ID`
+
 // Returns all the available Book Details
 func getAllBooks(c *gin.Context) {
 
@@ -26,9 +36,10 @@ func getAllBooks(c *gin.Context) {
 	}
 	defer db.Close()
 
-	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT * FROM BOOKMANAGEMENT;`
-	result, error := db.Query(queryToGetAllBooks)
+	// Query the DB for the user's Books and result is held into the variable, result
+	// The Book's cover is joined in
+	queryToGetAllBooks := queryToGetBooksWithCovers + ` WHERE USERID = $1;`
+	result, error := db.Query(queryToGetAllBooks, currentUserID(c))
 	// If there's any error when querying, return it
 	if error != nil {
 		c.JSON(500, gin.H{"status": "Could not execute Query"})
@@ -46,6 +57,7 @@ func getAllBooks(c *gin.Context) {
 		DateStarted  string `json:"dateStarted"`
 		DateFinished string `json:"dateFinished"`
 		Notes        string `json:"notes"`
+		CoverURL     string `json:"coverUrl"`
 	}
 
 	// Creating a slice from the struct
@@ -54,11 +66,18 @@ func getAllBooks(c *gin.Context) {
 	// Iterating over the results
 	for result.Next() {
 
-		//Creating a new struct
+		//Creating a new struct and a variable to hold the Book ID of the cover
 		GetBookDetails := GetBookDetails{}
+		var coverBookID string
+
 		// Scan the results into the struct
 		result.Scan(&GetBookDetails.ID, &GetBookDetails.Book, &GetBookDetails.Author, &GetBookDetails.TotalPages, &GetBookDetails.ReadPages,
-			&GetBookDetails.DateStarted, &GetBookDetails.DateFinished, &GetBookDetails.Notes)
+			&GetBookDetails.DateStarted, &GetBookDetails.DateFinished, &GetBookDetails.Notes, &coverBookID)
+
+		// If the Book has a cover, add its URL
+		if len(coverBookID) > 0 {
+			GetBookDetails.CoverURL = coverURL(GetBookDetails.ID)
+		}
 
 		//Converting Date which is in String to Integer and into DD-MMM-YYYY format
 		dateStartConversion, errs := strconv.Atoi(GetBookDetails.DateStarted)
@@ -103,8 +122,8 @@ func getAllUnreadBooks(c *gin.Context) {
 	defer db.Close()
 
 	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR FROM BOOKMANAGEMENT where DATESTARTED IS 0 AND DATEFINISHED IS 0;`
-	result, error := db.Query(queryToGetAllBooks)
+	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR FROM BOOKMANAGEMENT where USERID = $1 AND ` + unreadBooksCondition + `;`
+	result, error := db.Query(queryToGetAllBooks, currentUserID(c))
 	// If there's any error when querying, return it
 	if error != nil {
 		c.JSON(500, gin.H{"status": "Could not execute Query"})
@@ -154,8 +173,8 @@ func getAllReadingBooks(c *gin.Context) {
 	defer db.Close()
 
 	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, DATESTARTED, TOTALPAGES, READPAGES FROM BOOKMANAGEMENT where DATESTARTED IS NOT 0 AND DATEFINISHED IS 0;`
-	result, error := db.Query(queryToGetAllBooks)
+	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, DATESTARTED, TOTALPAGES, READPAGES FROM BOOKMANAGEMENT where USERID = $1 AND ` + readingBooksCondition + `;`
+	result, error := db.Query(queryToGetAllBooks, currentUserID(c))
 	// If there's any error when querying, return it
 	if error != nil {
 		c.JSON(500, gin.H{"status": "Could not execute Query"})
@@ -234,8 +253,8 @@ func getAllFinishedBooks(c *gin.Context) {
 	defer db.Close()
 
 	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, DATESTARTED, DATEFINISHED FROM BOOKMANAGEMENT where DATESTARTED IS NOT 0 AND DATEFINISHED IS NOT 0;`
-	result, error := db.Query(queryToGetAllBooks)
+	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, DATESTARTED, DATEFINISHED FROM BOOKMANAGEMENT where USERID = $1 AND ` + finishedBooksCondition + `;`
+	result, error := db.Query(queryToGetAllBooks, currentUserID(c))
 	// If there's any error when querying, return it
 	if error != nil {
 		c.JSON(500, gin.H{"status": "Could not execute Query"})
@@ -41,8 +41,8 @@ func getBookID(c *gin.Context) {
 
 	// Check if the Book and the Author exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2;`
-	result := db.QueryRow(queryToCheckExistingBook, sanitizeString(getBookIDParameters.BookName), sanitizeString(getBookIDParameters.AuthorName))
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND USERID=$3;`
+	result := db.QueryRow(queryToCheckExistingBook, sanitizeString(getBookIDParameters.BookName), sanitizeString(getBookIDParameters.AuthorName), currentUserID(c))
 	var checkResult string
 	result.Scan(&checkResult)
 
@@ -85,10 +85,39 @@ func getBookDetails(c *gin.Context) {
 	}
 	defer db.Close()
 
+	// Get the Book's details, if there is no book by that ID, its rejected with a 404
+	bookDetails, err := getBookDetailsResponse(db, getBookDetailsParameters.BookID, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if bookDetails == nil {
+		c.JSON(404, gin.H{"status": "No Book by ID, " + getBookDetailsParameters.BookID + " exists."})
+		return
+	}
+
+	// The ETag is worked out from all the details, so it changes whenever any of them do, even through a change to a related Book or a Genre
+	// If the client already has these details, return 304 without them
+	etag := bookDetailsETag(bookDetails)
+	c.Header("ETag", etag)
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && headerMatchesETag(ifNoneMatch, etag, true) {
+		c.Status(304)
+		return
+	}
+
+	c.JSON(200, bookDetails)
+
+}
+
+// Returns a Book's details in its library, with its current loan, ownership details, cover, genres, relations, metadata and version
+// Returns nil if there is no Book by that ID in the library
+func getBookDetailsResponse(db *sql.DB, bookID string, libraryID string) (gin.H, error) {
+
 	// Check if the exists in the DB by querying using the ID
-	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT * FROM BOOKMANAGEMENT WHERE ID = $1;`
-	result := db.QueryRow(queryToCheckExistingBook, getBookDetailsParameters.BookID)
+	// Result is scanned into the variable, checkResult, empty dates and notes are read as 0 and an empty string, so the version after them is always read
+	queryToCheckExistingBook := `SELECT ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, COALESCE(DATESTARTED, 0), COALESCE(DATEFINISHED, 0), COALESCE(NOTES, ''), VERSION
+	FROM BOOKMANAGEMENT WHERE ID = $1 AND USERID = $2;`
+	result := db.QueryRow(queryToCheckExistingBook, bookID, libraryID)
 
 	// Defining a struct to hold all the values from the Query result
 	type GetBookDetails struct {
@@ -100,6 +129,7 @@ func getBookDetails(c *gin.Context) {
 		DateStarted  int
 		DateFinished int
 		Notes        string
+		Version      int
 	}
 
 	// Creating an instance of the struct, GetBookDetails
@@ -107,18 +137,97 @@ func getBookDetails(c *gin.Context) {
 
 	// Scan the query result into the struct's members
 	result.Scan(&getBookDetails.ID, &getBookDetails.Book, &getBookDetails.Author, &getBookDetails.TotalPages, &getBookDetails.ReadPages,
-		&getBookDetails.DateStarted, &getBookDetails.DateFinished, &getBookDetails.Notes)
-
-	// If the length of getBookDetails.ID is greater than 0, means the query returned a result, so there is a book by that ID
-	// We return all the details
-	// Else, its rejected with a 404 as there is no book by that ID
-	if len(getBookDetails.ID) > 0 {
-		c.JSON(200, gin.H{"bookID": getBookDetails.ID, "book": getBookDetails.Book, "author": getBookDetails.Author, "totalPages": getBookDetails.TotalPages,
-			"readPages": getBookDetails.ReadPages, "dateStarted": convertEpochToDate(getBookDetails.DateStarted), "dateFinished": convertEpochToDate(getBookDetails.DateFinished),
-			"notes": getBookDetails.Notes})
-	} else {
-		c.JSON(404, gin.H{"status": "No Book by ID, " + getBookDetailsParameters.BookID + " exists."})
+		&getBookDetails.DateStarted, &getBookDetails.DateFinished, &getBookDetails.Notes, &getBookDetails.Version)
+
+	// If the length of getBookDetails.ID is 0, means the query returned no result, so there is no book by that ID
+	if len(getBookDetails.ID) == 0 {
+		return nil, nil
+	}
+
+	// Get the Book's current loan, which is the loan that is not returned
+	queryToGetCurrentLoan := `SELECT ID, BORROWER, DATELENT, DATEDUE FROM BOOKLOANS WHERE BOOKID = $1 AND DATERETURNED = 0;`
+	resultToGetCurrentLoan := db.QueryRow(queryToGetCurrentLoan, getBookDetails.ID)
+
+	// Defining a struct to hold the current loan and scanning into it
+	type GetCurrentLoan struct {
+		ID       string
+		Borrower string
+		DateLent int
+		DateDue  int
+	}
+	var getCurrentLoan GetCurrentLoan
+	resultToGetCurrentLoan.Scan(&getCurrentLoan.ID, &getCurrentLoan.Borrower, &getCurrentLoan.DateLent, &getCurrentLoan.DateDue)
+
+	// If the Book is lent out, return the loan, else currentLoan is returned as null
+	var currentLoan gin.H
+	if len(getCurrentLoan.ID) > 0 {
+		currentLoan = gin.H{"loanID": getCurrentLoan.ID, "borrower": getCurrentLoan.Borrower, "dateLent": convertEpochToDate(getCurrentLoan.DateLent),
+			"dateDue": convertEpochToDate(getCurrentLoan.DateDue)}
+	}
+
+	// Get the physical copy's location and ownership details
+	queryToGetOwnership := `SELECT ROOM, SHELF, BOX, DATEACQUIRED, SOURCE, PRICEPAID, CONDITION FROM BOOKOWNERSHIP WHERE BOOKID = $1;`
+	resultToGetOwnership := db.QueryRow(queryToGetOwnership, getBookDetails.ID)
+
+	// Defining a struct to hold the ownership details and scanning into it
+	type GetOwnership struct {
+		Room         string
+		Shelf        string
+		Box          string
+		DateAcquired int
+		Source       string
+		PricePaid    float64
+		Condition    string
+	}
+	var getOwnership GetOwnership
+
+	// If the Book has ownership details, return them, else ownership is returned as null
+	var ownership gin.H
+	if resultToGetOwnership.Scan(&getOwnership.Room, &getOwnership.Shelf, &getOwnership.Box, &getOwnership.DateAcquired, &getOwnership.Source,
+		&getOwnership.PricePaid, &getOwnership.Condition) == nil {
+		ownership = gin.H{"room": getOwnership.Room, "shelf": getOwnership.Shelf, "box": getOwnership.Box, "dateAcquired": convertEpochToDate(getOwnership.DateAcquired),
+			"source": getOwnership.Source, "pricePaid": getOwnership.PricePaid, "condition": getOwnership.Condition}
+	}
+
+	// Check if the Book has a cover, if yes return its URL, else coverUrl is returned as an empty string
+	queryToCheckCover := `SELECT BOOKID FROM BOOKCOVERS WHERE BOOKID = $1;`
+	resultToCheckCover := db.QueryRow(queryToCheckCover, getBookDetails.ID)
+	var checkCover, coverUrl string
+	resultToCheckCover.Scan(&checkCover)
+	if len(checkCover) > 0 {
+		coverUrl = coverURL(getBookDetails.ID)
+	}
+
+	// Get the Book's genres, with their full path in the genre tree
+	genrePaths, genreErr := getGenrePaths(db, libraryID)
+	if genreErr != nil {
+		return nil, genreErr
+	}
+	queryToGetBookGenres := `SELECT BOOKGENRES.GENREID FROM BOOKGENRES INNER JOIN GENRES ON GENRES.ID = BOOKGENRES.GENREID
+	WHERE BOOKGENRES.BOOKID = $1 AND GENRES.USERID = $2;`
+	resultToGetBookGenres, genreErr := db.Query(queryToGetBookGenres, getBookDetails.ID, libraryID)
+	if genreErr != nil {
+		return nil, genreErr
+	}
+	defer resultToGetBookGenres.Close()
+	genres := []gin.H{}
+	for resultToGetBookGenres.Next() {
+		var genreID string
+		resultToGetBookGenres.Scan(&genreID)
+		genres = append(genres, gin.H{"genreID": genreID, "path": genrePaths[genreID]})
 	}
+
+	// Get the Book's relations to other Books, from both sides
+	relations, relationErr := getBookRelations(db, getBookDetails.ID)
+	if relationErr != nil {
+		return nil, relationErr
+	}
+
+	return gin.H{"bookID": getBookDetails.ID, "book": getBookDetails.Book, "author": getBookDetails.Author, "totalPages": getBookDetails.TotalPages,
+		"readPages": getBookDetails.ReadPages, "dateStarted": convertEpochToDate(getBookDetails.DateStarted), "dateFinished": convertEpochToDate(getBookDetails.DateFinished),
+		"notes": getBookDetails.Notes, "currentLoan": currentLoan, "ownership": ownership, "coverUrl": coverUrl, "genres": genres, "relations": relations,
+		"metadata": getBookMetadata(db, getBookDetails.ID), "version": getBookDetails.Version}, nil
+
 }
 
 // Defining JSON body for getBooksByAuthor(). It requires 2 Query Parameters book, author.
@@ -151,8 +260,8 @@ func getBooksByAuthor(c *gin.Context) {
 	defer db.Close()
 
 	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT ID, BOOK FROM BOOKMANAGEMENT WHERE AUTHOR = $1;`
-	result, error := db.Query(queryToGetAllBooks, getBooksByAuthorParameters.AuthorName)
+	queryToGetAllBooks := `SELECT ID, BOOK FROM BOOKMANAGEMENT WHERE AUTHOR = $1 AND USERID = $2;`
+	result, error := db.Query(queryToGetAllBooks, getBooksByAuthorParameters.AuthorName, currentUserID(c))
 	// If there's any error when querying, return it
 	if error != nil {
 		c.JSON(500, gin.H{"status": "Could not execute Query"})
@@ -230,8 +339,9 @@ func getBooksReadInAPeriod(c *gin.Context) {
 	}
 
 	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, DATESTARTED, DATEFINISHED FROM BOOKMANAGEMENT WHERE DATESTARTED BETWEEN $1 AND $2 AND DATEFINISHED BETWEEN $1 AND $2;`
-	result, error := db.Query(queryToGetAllBooks, convertDateToEpoch(getBooksReadInAPeriodParameters.FromDate), convertDateToEpoch(getBooksReadInAPeriodParameters.ToDate))
+	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, DATESTARTED, DATEFINISHED FROM BOOKMANAGEMENT WHERE DATESTARTED BETWEEN $1 AND $2 AND DATEFINISHED BETWEEN $1 AND $2 AND USERID = $3;`
+	result, error := db.Query(queryToGetAllBooks, convertDateToEpoch(getBooksReadInAPeriodParameters.FromDate), convertDateToEpoch(getBooksReadInAPeriodParameters.ToDate),
+		currentUserID(c))
 	// If there's any error when querying, return it
 	if error != nil {
 		c.JSON(500, gin.H{"status": "Could not execute Query"})
@@ -323,8 +433,8 @@ func getBookContaining(c *gin.Context) {
 	defer db.Close()
 
 	// Query the DB and result is held into the variable, result
-	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR FROM BOOKMANAGEMENT WHERE BOOK LIKE '%' || $1 || '%';`
-	result, error := db.Query(queryToGetAllBooks, getBooksContainingParameters.Name)
+	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR FROM BOOKMANAGEMENT WHERE BOOK LIKE '%' || $1 || '%' AND USERID = $2;`
+	result, error := db.Query(queryToGetAllBooks, getBooksContainingParameters.Name, currentUserID(c))
 
 	// If there's any error when querying, return it
 	if error != nil {
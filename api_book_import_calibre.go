@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defining JSON body for importCalibre(). It requires 1 JSON key libraryPath, which is the Calibre library folder or its metadata.db
+// pagesColumn is the lookup name of the Calibre custom column holding the page count, it defaults to pages, as used by the Count Pages plugin
+// preview and duplicates are optional, duplicates can be skip, which is the default, or update
+type ImportCalibreParameters struct {
+	LibraryPath string `json:"libraryPath" binding:"required"`
+	PagesColumn string `json:"pagesColumn"`
+	Preview     bool   `json:"preview"`
+	Duplicates  string `json:"duplicates"`
+}
+
+// A Book read from a Calibre library, with the path to its cover, if it has one
+type CalibreBook struct {
+	ImportedBook
+	CoverPath string
+}
+
+// Imports Books from a local Calibre library, its metadata.db is only ever opened read only
+// Series, series index, tags and ISBN are added to the Book's notes, the covers are imported alongside the Books
+// In preview mode, nothing is saved, but the summary is the same as if it was
+func importCalibre(c *gin.Context) {
+
+	// Variables for DB and Error
+	var calibreDB *sql.DB
+	var err error
+
+	// Creating an instance of the struct, ImportCalibreParameters
+	var importCalibreParameters ImportCalibreParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&importCalibreParameters) != nil || !checkDuplicatesMode(importCalibreParameters.Duplicates) {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters, duplicates should be skip or update"})
+		return
+	}
+	if importCalibreParameters.PagesColumn == "" {
+		importCalibreParameters.PagesColumn = "pages"
+	}
+
+	// The library path can be the library folder or the metadata.db inside it, if the metadata.db is not there, reject with 404
+	libraryDirectory := importCalibreParameters.LibraryPath
+	if filepath.Base(libraryDirectory) == "metadata.db" {
+		libraryDirectory = filepath.Dir(libraryDirectory)
+	}
+	metadataPath := filepath.Join(libraryDirectory, "metadata.db")
+	if fileInfo, err := os.Stat(metadataPath); err != nil || fileInfo.IsDir() {
+		c.JSON(404, gin.H{"status": "No Calibre library found at " + importCalibreParameters.LibraryPath})
+		return
+	}
+
+	// Connect to the Calibre DB in read only mode, so nothing in the Calibre library can be changed
+	calibreDB, err = sql.Open("sqlite", "file:"+(&url.URL{Path: filepath.ToSlash(metadataPath)}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to the Calibre DB"})
+		return
+	}
+	defer calibreDB.Close()
+
+	// Read the Books from the Calibre library
+	calibreBooks, status := readCalibreBooks(calibreDB, libraryDirectory, strings.TrimPrefix(importCalibreParameters.PagesColumn, "#"))
+	if status != "" {
+		c.JSON(400, gin.H{"status": status})
+		return
+	}
+
+	// Import the Books and return the summary
+	importedBooks := []ImportedBook{}
+	for _, calibreBook := range calibreBooks {
+		importedBooks = append(importedBooks, calibreBook.ImportedBook)
+	}
+	summary, err := importBooks(currentUserID(c), importedBooks, importCalibreParameters.Duplicates == "update", importCalibreParameters.Preview)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	delete(summary, "dryRun")
+	summary["preview"] = importCalibreParameters.Preview
+
+	// Import the covers of the Books which were added or updated, nothing is saved in preview mode
+	// A cover which cannot be read is left out, the Book is still imported
+	coversImported := 0
+	if !importCalibreParameters.Preview {
+		db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not connect to DB"})
+			return
+		}
+		defer db.Close()
+
+		for index, rowResult := range summary["rows"].([]ImportedRowResult) {
+			if (rowResult.Action != "created" && rowResult.Action != "updated") || calibreBooks[index].CoverPath == "" {
+				continue
+			}
+			if importCalibreCover(db, rowResult.BookID, calibreBooks[index].CoverPath) {
+				coversImported++
+			}
+		}
+	}
+	summary["coversImported"] = coversImported
+
+	c.JSON(200, summary)
+
+}
+
+// Reads all the Books from a Calibre DB, with their authors, series, tags, ISBN, page count and cover
+// The page count is read from the custom column with the given lookup name, it should be an integer or float column
+// Returns the reason as the last value if the Books cannot be read
+func readCalibreBooks(calibreDB *sql.DB, libraryDirectory string, pagesColumn string) ([]CalibreBook, string) {
+
+	// Find the custom column holding the page count, Calibre keeps the values of each custom column in its own table, custom_column_<ID>
+	queryToGetPagesColumn := `SELECT ID FROM custom_columns WHERE LABEL = $1 AND DATATYPE IN ('int', 'float');`
+	var pagesColumnID int
+	if calibreDB.QueryRow(queryToGetPagesColumn, pagesColumn).Scan(&pagesColumnID) != nil {
+		return nil, "No integer custom column #" + pagesColumn + " found in the Calibre library, provide the column holding the page count as pagesColumn"
+	}
+
+	// Authors are joined in the order they are listed in Calibre, an ISBN is taken from the identifiers
+	queryToGetCalibreBooks := `SELECT books.id, books.title, books.path, books.has_cover, COALESCE(books.series_index, 1),
+	COALESCE((SELECT GROUP_CONCAT(name, ' & ') FROM (SELECT authors.name FROM books_authors_link INNER JOIN authors ON authors.id = books_authors_link.author
+		WHERE books_authors_link.book = books.id ORDER BY books_authors_link.id)), ''),
+	COALESCE((SELECT series.name FROM books_series_link INNER JOIN series ON series.id = books_series_link.series WHERE books_series_link.book = books.id), ''),
+	COALESCE((SELECT GROUP_CONCAT(name, ', ') FROM (SELECT tags.name FROM books_tags_link INNER JOIN tags ON tags.id = books_tags_link.tag
+		WHERE books_tags_link.book = books.id ORDER BY tags.name)), ''),
+	COALESCE((SELECT val FROM identifiers WHERE identifiers.book = books.id AND identifiers.type = 'isbn'), ''),
+	COALESCE((SELECT CAST(value AS INTEGER) FROM custom_column_` + strconv.Itoa(pagesColumnID) + ` WHERE book = books.id), 0)
+	FROM books ORDER BY books.id;`
+	result, err := calibreDB.Query(queryToGetCalibreBooks)
+	if err != nil {
+		return nil, "Could not read the Calibre library, " + err.Error()
+	}
+	defer result.Close()
+
+	calibreBooks := []CalibreBook{}
+	for result.Next() {
+
+		var calibreID, hasCover int
+		var bookPath, series, tags, isbn string
+		var seriesIndex float64
+		calibreBook := CalibreBook{ImportedBook: ImportedBook{Row: len(calibreBooks) + 1}}
+		if err = result.Scan(&calibreID, &calibreBook.Book, &bookPath, &hasCover, &seriesIndex, &calibreBook.Author, &series, &tags, &isbn, &calibreBook.TotalPages); err != nil {
+			return nil, "Could not read the Calibre library, " + err.Error()
+		}
+
+		// A Book without a page count cannot be added, so it is reported as an error
+		if calibreBook.TotalPages <= 0 {
+			calibreBook.ParseError = "Calibre Book " + strconv.Itoa(calibreID) + " has no page count in #" + pagesColumn
+		}
+
+		// Adding the series, tags and ISBN to the notes
+		notes := []string{}
+		if series != "" {
+			notes = append(notes, "Series: "+series+" #"+strconv.FormatFloat(seriesIndex, 'f', -1, 64)+".")
+		}
+		if tags != "" {
+			notes = append(notes, "Tags: "+tags+".")
+		}
+		if isbn != "" {
+			notes = append(notes, "ISBN: "+isbn+".")
+		}
+		calibreBook.Notes = strings.Join(notes, " ")
+
+		// Calibre keeps the cover as cover.jpg in the Book's folder
+		if hasCover == 1 {
+			calibreBook.CoverPath = filepath.Join(libraryDirectory, filepath.FromSlash(bookPath), "cover.jpg")
+		}
+
+		calibreBooks = append(calibreBooks, calibreBook)
+	}
+	if result.Err() != nil {
+		return nil, "Could not read the Calibre library, " + result.Err().Error()
+	}
+
+	return calibreBooks, ""
+
+}
+
+// Imports a cover from a Calibre library for a Book, replacing any existing cover, same as uploadCover()
+// Returns TRUE if the cover was imported, or FALSE if it could not be read or saved
+func importCalibreCover(db *sql.DB, bookID string, coverPath string) bool {
+
+	fileInfo, err := os.Stat(coverPath)
+	if err != nil || fileInfo.Size() > maxCoverSize {
+		return false
+	}
+
+	coverImage, err := os.ReadFile(coverPath)
+	if err != nil {
+		return false
+	}
+
+	contentType, fileExtension, decodedImage, coverError := checkCoverImage(coverImage)
+	if coverError != "" {
+		return false
+	}
+
+	return saveCover(db, bookID, coverImage, contentType, fileExtension, decodedImage) == nil
+
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Columns of the CSV export, in the same order as the columns of BOOKMANAGEMENT
+// The import reads the same columns by name, ID is ignored as Books are matched by their name and author
+var csvColumns = []string{"id", "book", "author", "totalPages", "readPages", "dateStarted", "dateFinished", "notes"}
+
+// A Book read from an import file, before it is validated and saved
+// ParseError is set when the row could not be read, such rows are reported as errors
+type ImportedBook struct {
+	Row          int
+	ParseError   string
+	Book         string
+	Author       string
+	TotalPages   int
+	ReadPages    int
+	DateStarted  string
+	DateFinished string
+	Notes        string
+}
+
+// The outcome of importing one row of an import file
+type ImportedRowResult struct {
+	Row    int    `json:"row"`
+	Book   string `json:"book"`
+	Author string `json:"author"`
+	BookID string `json:"bookID,omitempty"`
+	Action string `json:"action"`
+	Status string `json:"status,omitempty"`
+}
+
+// Streams every Book in the user's library as a CSV file, with the dates in DD-MMM-YYYY format
+func exportCSV(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetAllBooks := `SELECT ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, COALESCE(NOTES, '') FROM BOOKMANAGEMENT WHERE USERID = $1 ORDER BY BOOK;`
+	result, error := db.Query(queryToGetAllBooks, currentUserID(c))
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Send the CSV as a file download, starting with the header row
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="BOOKMANAGEMENT.csv"`)
+	csvWriter := csv.NewWriter(c.Writer)
+	csvWriter.Write(csvColumns)
+
+	// Iterating over the results, each row is written and flushed as its read, so the whole library is never held in memory
+	for result.Next() {
+
+		// Variables to hold the values from the Query result
+		var id, book, author, notes string
+		var totalPages, readPages, dateStarted, dateFinished int
+
+		// Scan the results into the variables
+		result.Scan(&id, &book, &author, &totalPages, &readPages, &dateStarted, &dateFinished, &notes)
+
+		// Write the row, converting the dates from Epoch time into DD-MMM-YYYY format
+		csvWriter.Write([]string{id, book, author, strconv.Itoa(totalPages), strconv.Itoa(readPages), convertEpochToDate(dateStarted), convertEpochToDate(dateFinished), notes})
+		csvWriter.Flush()
+	}
+
+	csvWriter.Flush()
+
+}
+
+// Defining form body for importCSV(). It requires 1 file, file, dryRun and duplicates are optional and can also be Query Parameters.
+// duplicates can be skip, which is the default, or update
+type ImportCSVParameters struct {
+	DryRun     bool   `form:"dryRun"`
+	Duplicates string `form:"duplicates"`
+}
+
+// Imports Books from a CSV file, in the same format as exportCSV()
+// Each row is validated with the same rules as addABook(), startABook() and finishABook(), rows with errors are reported and not imported
+func importCSV(c *gin.Context) {
+
+	// Creating an instance of the struct, ImportCSVParameters
+	var importCSVParameters ImportCSVParameters
+
+	// Bind to the struct's members, from the form or the Query Parameters, and get the uploaded file. If any of them are invalid, its rejected with 400
+	fileHeader, fileErr := c.FormFile("file")
+	if c.ShouldBindWith(&importCSVParameters, binding.Form) != nil || fileErr != nil || !checkDuplicatesMode(importCSVParameters.Duplicates) {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide a CSV file, duplicates should be skip or update"})
+		return
+	}
+
+	// Read the uploaded file
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"status": "Could not read the CSV file"})
+		return
+	}
+	defer file.Close()
+
+	// Read the rows, the columns book, author and totalPages are required
+	csvRows, err := readCSVRows(file, []string{"book", "author", "totalPages"})
+	if err != nil {
+		c.JSON(400, gin.H{"status": "Could not read the CSV file, it should start with a header row having the columns book, author and totalPages"})
+		return
+	}
+
+	// Convert each row into a Book, pages which are not numbers are kept as -1, so they fail validation
+	importedBooks := []ImportedBook{}
+	for _, csvRow := range csvRows {
+		importedBooks = append(importedBooks, ImportedBook{Row: csvRow.Row, ParseError: csvRow.ParseError, Book: csvRow.Value("book"), Author: csvRow.Value("author"),
+			TotalPages: parsePages(csvRow.Value("totalPages")), ReadPages: parsePages(csvRow.Value("readPages")), DateStarted: csvRow.Value("dateStarted"),
+			DateFinished: csvRow.Value("dateFinished"), Notes: csvRow.Value("notes")})
+	}
+
+	// Import the Books and return the summary
+	summary, err := importBooks(currentUserID(c), importedBooks, importCSVParameters.Duplicates == "update", importCSVParameters.DryRun)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	c.JSON(200, summary)
+
+}
+
+// A row read from a CSV file, with its values keyed by the column names in the header row
+// ParseError is set when the row could not be read
+type CSVRow struct {
+	Row        int
+	Values     map[string]string
+	ParseError string
+}
+
+// Returns the value of a column in the row, the column name is matched ignoring case
+// Returns an empty string if the row does not have that column
+func (csvRow CSVRow) Value(column string) string {
+
+	return csvRow.Values[strings.ToLower(column)]
+
+}
+
+// Reads all the rows of a CSV file, the first row should be a header row with the column names
+// Returns an error if the header row cannot be read or if any of the required columns are missing in it
+func readCSVRows(file io.Reader, requiredColumns []string) ([]CSVRow, error) {
+
+	// Read the header row and find the position of each column by its name, ignoring case
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columnPositions := map[string]int{}
+	for position, column := range header {
+		columnPositions[strings.ToLower(strings.TrimSpace(column))] = position
+	}
+	for _, requiredColumn := range requiredColumns {
+		if _, found := columnPositions[strings.ToLower(requiredColumn)]; !found {
+			return nil, errors.New("missing column, " + requiredColumn)
+		}
+	}
+
+	// Read all the rows, the header is row 1
+	csvRows := []CSVRow{}
+	for row := 2; ; row++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			csvRows = append(csvRows, CSVRow{Row: row, ParseError: "Could not parse the row, " + err.Error()})
+			continue
+		}
+
+		// Key the values by their column name, a row which is shorter than the header gets empty values
+		values := map[string]string{}
+		for column, position := range columnPositions {
+			if position < len(record) {
+				values[column] = strings.TrimSpace(record[position])
+			}
+		}
+		csvRows = append(csvRows, CSVRow{Row: row, Values: values})
+	}
+
+	return csvRows, nil
+
+}
+
+// Checks if the duplicates mode of an import is supported, an empty mode means skip
+// Returns TRUE if yes, or FALSE if not
+func checkDuplicatesMode(duplicates string) bool {
+
+	return duplicates == "" || duplicates == "skip" || duplicates == "update"
+
+}
+
+// Converts the pages in an import file to a number, an empty value is 0
+// Returns -1 if the value is not a number
+func parsePages(pages string) int {
+
+	if pages == "" {
+		return 0
+	}
+
+	parsedPages, err := strconv.Atoi(pages)
+	if err != nil {
+		return -1
+	}
+
+	return parsedPages
+
+}
+
+// Validates a Book read from an import file, with the same rules as addABook(), startABook(), updateABook() and finishABook()
+// Returns the reason the Book is invalid, or an empty string if its valid
+func validateImportedBook(importedBook ImportedBook) string {
+
+	// Rows which could not be read are invalid
+	if importedBook.ParseError != "" {
+		return importedBook.ParseError
+	}
+
+	// Book, author and total pages are required, same as addABook()
+	if len(sanitizeString(importedBook.Book)) == 0 || len(sanitizeString(importedBook.Author)) == 0 {
+		return "Incorrect parameters, please provide all required parameters"
+	}
+
+	// A missing page count gets its own reason, as exports from other apps often leave it empty
+	if importedBook.TotalPages == 0 {
+		return "Missing page count, the total pages of the Book are required"
+	}
+	if importedBook.TotalPages < 0 {
+		return "Total pages should be a number greater than 0."
+	}
+
+	// Dates should be in DD-MMM-YYYY format, same as startABook() and finishABook()
+	if (importedBook.DateStarted != "" && !checkDateFormat(importedBook.DateStarted)) || (importedBook.DateFinished != "" && !checkDateFormat(importedBook.DateFinished)) {
+		return "Incorrect Date format, Date should be in DD-MMM-YYYY format, e.g., 27-Aug-2024"
+	}
+
+	// A Book can only be finished if it is started, same as finishABook()
+	if importedBook.DateFinished != "" && importedBook.DateStarted == "" {
+		return "Book is not started, so it cannot be finished."
+	}
+
+	// The finished date cannot be less than the started date, same as finishABook()
+	if importedBook.DateFinished != "" && convertDateToEpoch(importedBook.DateStarted) > convertDateToEpoch(importedBook.DateFinished) {
+		return "Finished date cannot be less than Started date"
+	}
+
+	// Read pages cannot be negative, and for a Book being read, they cannot be greater or equal to the total pages, same as updateABook()
+	if importedBook.ReadPages < 0 {
+		return "Read pages should be a number."
+	}
+	if importedBook.DateStarted != "" && importedBook.DateFinished == "" && importedBook.ReadPages >= importedBook.TotalPages {
+		return "Read pages cannot be greater or equal to Total pages."
+	}
+
+	// A Book which is not started cannot have read pages, same as updateABook()
+	if importedBook.DateStarted == "" && importedBook.ReadPages > 0 {
+		return "Book is not started, so it cannot have read pages."
+	}
+
+	// Notes cannot be longer than MAX_NOTE_LENGTH, same as addNote()
+	if utf8.RuneCountInString(sanitizeString(importedBook.Notes)) > maxNoteLength {
+		return noteTooLongStatus()
+	}
+
+	return ""
+
+}
+
+// Validates and saves Books read from an import file into a user's library, it is shared by all the importers
+// Books which already exist in the library, by name and author, are skipped, or updated if updateDuplicates is TRUE
+// If dryRun is TRUE, nothing is saved, but the summary is the same as if it was
+// Returns a summary with the number of Books created, updated, skipped and with errors, and the result of each row
+func importBooks(userID string, importedBooks []ImportedBook, updateDuplicates bool, dryRun bool) (gin.H, error) {
+
+	// Connect to the DB
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// All the rows are saved in a single transaction, which is rolled back for a dry run
+	transaction, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.Rollback()
+
+	// Counters for the summary and the result of each row
+	created, updated, skipped, withErrors := 0, 0, 0, 0
+	rows := []ImportedRowResult{}
+
+	for _, importedBook := range importedBooks {
+
+		rowResult := ImportedRowResult{Row: importedBook.Row, Book: sanitizeString(importedBook.Book), Author: sanitizeString(importedBook.Author)}
+
+		// If the row is invalid, report it and move to the next row
+		if validationError := validateImportedBook(importedBook); validationError != "" {
+			rowResult.Action, rowResult.Status = "error", validationError
+			rows = append(rows, rowResult)
+			withErrors++
+			continue
+		}
+
+		// Converting the dates into Epoch time, a finished Book has all its pages read, same as finishABook()
+		dateStarted, dateFinished, readPages := 0, 0, importedBook.ReadPages
+		if importedBook.DateStarted != "" {
+			dateStarted = convertDateToEpoch(importedBook.DateStarted)
+		}
+		if importedBook.DateFinished != "" {
+			dateFinished = convertDateToEpoch(importedBook.DateFinished)
+			readPages = importedBook.TotalPages
+		}
+
+		// Check if the Book and the Author exists in the user's library by querying for the ID, this includes Books added by earlier rows
+		queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND USERID=$3;`
+		resultToCheckExistingBook := transaction.QueryRow(queryToCheckExistingBook, rowResult.Book, rowResult.Author, userID)
+		var checkResult string
+		resultToCheckExistingBook.Scan(&checkResult)
+
+		if len(checkResult) > 0 && !updateDuplicates {
+
+			// The Book exists and duplicates are skipped
+			rowResult.BookID, rowResult.Action = checkResult, "skipped"
+			rowResult.Status = "Book, " + rowResult.Book + " by " + rowResult.Author + " already exists"
+			skipped++
+
+		} else if len(checkResult) > 0 {
+
+			// The Book exists and duplicates are updated
+			queryToUpdateABook := `UPDATE BOOKMANAGEMENT SET TOTALPAGES = $1, READPAGES = $2, DATESTARTED = $3, DATEFINISHED = $4, NOTES = $5, VERSION = VERSION + 1 WHERE ID = $6;`
+			_, err = transaction.Exec(queryToUpdateABook, importedBook.TotalPages, readPages, dateStarted, dateFinished, sanitizeString(importedBook.Notes), checkResult)
+			if err != nil {
+				return nil, err
+			}
+			rowResult.BookID, rowResult.Action = checkResult, "updated"
+			updated++
+
+		} else {
+
+			// The Book does not exist, its added, same as addABook()
+			generatedID := uniqueIDGenerator()
+			queryToAddABook := `INSERT INTO BOOKMANAGEMENT (ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+			_, err = transaction.Exec(queryToAddABook, generatedID, rowResult.Book, rowResult.Author, importedBook.TotalPages, readPages, dateStarted, dateFinished,
+				sanitizeString(importedBook.Notes), userID)
+			if err != nil {
+				return nil, err
+			}
+
+			// Record when the Book was added, for the recently added feed
+			queryToRecordAddition := `INSERT INTO BOOKADDITIONS (BOOKID, DATEADDED) VALUES ($1, $2);`
+			if _, err = transaction.Exec(queryToRecordAddition, generatedID, time.Now().Unix()); err != nil {
+				return nil, err
+			}
+			rowResult.Action = "created"
+			created++
+
+			// The generated ID is only returned if the Book is saved
+			if !dryRun {
+				rowResult.BookID = generatedID
+			}
+
+		}
+
+		rows = append(rows, rowResult)
+	}
+
+	// Save everything, unless its a dry run
+	if !dryRun {
+		if err = transaction.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	return gin.H{"dryRun": dryRun, "created": created, "updated": updated, "skipped": skipped, "errors": withErrors, "rows": rows}, nil
+
+}
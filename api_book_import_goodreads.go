@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Defining form body for importGoodreads(). It requires 1 file, file, preview, duplicates and defaultPages are optional and can also be Query Parameters.
+// duplicates can be skip, which is the default, or update, and defaultPages is used for the Books which have no Number of Pages
+type ImportGoodreadsParameters struct {
+	Preview      bool   `form:"preview"`
+	Duplicates   string `form:"duplicates"`
+	DefaultPages int    `form:"defaultPages"`
+}
+
+// Imports Books from a Goodreads library export CSV file
+// The Exclusive Shelf decides if a Book is unread, being read or finished, My Rating, ISBN13 and My Review are added to the Book's notes
+// In preview mode, nothing is saved, but the summary is the same as if it was
+func importGoodreads(c *gin.Context) {
+
+	// Creating an instance of the struct, ImportGoodreadsParameters
+	var importGoodreadsParameters ImportGoodreadsParameters
+
+	// Bind to the struct's members, from the form or the Query Parameters, and get the uploaded file. If any of them are invalid, its rejected with 400
+	fileHeader, fileErr := c.FormFile("file")
+	if c.ShouldBindWith(&importGoodreadsParameters, binding.Form) != nil || fileErr != nil || !checkDuplicatesMode(importGoodreadsParameters.Duplicates) ||
+		importGoodreadsParameters.DefaultPages < 0 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide a Goodreads export file, duplicates should be skip or update, defaultPages should not be negative"})
+		return
+	}
+
+	// Read the uploaded file
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"status": "Could not read the Goodreads export file"})
+		return
+	}
+	defer file.Close()
+
+	// Read the rows, the columns Title, Author and Exclusive Shelf are required
+	csvRows, err := readCSVRows(file, []string{"Title", "Author", "Exclusive Shelf"})
+	if err != nil {
+		c.JSON(400, gin.H{"status": "Could not read the Goodreads export file, it should have the columns Title, Author and Exclusive Shelf"})
+		return
+	}
+
+	// Convert each row into a Book
+	importedBooks := []ImportedBook{}
+	for _, csvRow := range csvRows {
+		importedBooks = append(importedBooks, convertGoodreadsRow(csvRow, importGoodreadsParameters.DefaultPages))
+	}
+
+	// Import the Books and return the summary
+	summary, err := importBooks(currentUserID(c), importedBooks, importGoodreadsParameters.Duplicates == "update", importGoodreadsParameters.Preview)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	delete(summary, "dryRun")
+	summary["preview"] = importGoodreadsParameters.Preview
+	c.JSON(200, summary)
+
+}
+
+// Converts a row of a Goodreads export into a Book
+// Books on the read shelf are finished on their Date Read, and started on their Date Added, if it is before the Date Read
+// Books on the currently-reading shelf are started on their Date Added, Books on any other shelf are unread
+// Books with no Number of Pages get the default page count, if it is 0 they fail validation as their page count is missing
+func convertGoodreadsRow(csvRow CSVRow, defaultPages int) ImportedBook {
+
+	importedBook := ImportedBook{Row: csvRow.Row, ParseError: csvRow.ParseError, Book: csvRow.Value("Title"), Author: csvRow.Value("Author"),
+		TotalPages: parsePages(csvRow.Value("Number of Pages"))}
+	if csvRow.Value("Number of Pages") == "" {
+		importedBook.TotalPages = defaultPages
+	}
+	if importedBook.ParseError != "" {
+		return importedBook
+	}
+
+	// Goodreads dates are in YYYY/MM/DD format, they are converted to DD-MMM-YYYY format
+	// A date which cannot be converted is kept as it is, so it fails validation
+	dateRead := convertGoodreadsDate(csvRow.Value("Date Read"))
+	dateAdded := convertGoodreadsDate(csvRow.Value("Date Added"))
+
+	switch csvRow.Value("Exclusive Shelf") {
+	case "read":
+		importedBook.DateFinished = dateRead
+		importedBook.DateStarted = dateRead
+		if importedBook.DateFinished == "" {
+			importedBook.DateFinished = dateAdded
+			importedBook.DateStarted = dateAdded
+		} else if checkDateFormat(dateAdded) && checkDateFormat(dateRead) && convertDateToEpoch(dateAdded) < convertDateToEpoch(dateRead) {
+			importedBook.DateStarted = dateAdded
+		}
+	case "currently-reading":
+		importedBook.DateStarted = dateAdded
+	}
+
+	// Goodreads exports the ISBN13 as ="9780000000000", only the digits are kept
+	isbn13 := strings.Trim(csvRow.Value("ISBN13"), `="`)
+
+	// Adding the rating, ISBN13 and review to the notes, reviews use <br/> for line breaks, which are replaced with spaces
+	notes := []string{}
+	if rating := csvRow.Value("My Rating"); rating != "" && rating != "0" {
+		notes = append(notes, "My Rating: "+rating+"/5.")
+	}
+	if isbn13 != "" {
+		notes = append(notes, "ISBN13: "+isbn13+".")
+	}
+	if review := csvRow.Value("My Review"); review != "" {
+		notes = append(notes, strings.NewReplacer("<br/>", " ", "<br />", " ", "<br>", " ").Replace(review))
+	}
+	importedBook.Notes = strings.Join(notes, " ")
+
+	return importedBook
+
+}
+
+// Converts a date in the Goodreads YYYY/MM/DD format to DD-MMM-YYYY format
+// Returns an empty string for an empty date, and the date as it is if it cannot be converted
+func convertGoodreadsDate(date string) string {
+
+	if date == "" {
+		return ""
+	}
+
+	parsedDate, err := time.Parse("2006/01/02", date)
+	if err != nil {
+		return date
+	}
+
+	return parsedDate.Format("02-Jan-2006")
+
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defining JSON body for lendABook(). It requires 4 JSON key's bookID, borrower, dateLent, dateDue.
+type LendABookParameters struct {
+	BookID   string `json:"bookID" binding:"required"`
+	Borrower string `json:"borrower" binding:"required"`
+	DateLent string `json:"dateLent" binding:"required"`
+	DateDue  string `json:"dateDue" binding:"required"`
+}
+
+// Lends a Book to a borrower by adding a loan to the BOOKLOANS table
+func lendABook(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, LendABookParameters
+	var lendABookParameters LendABookParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&lendABookParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Checks if the supplied dates are in DD-MMM-YYYY format
+	if !checkDateFormat(lendABookParameters.DateLent) || !checkDateFormat(lendABookParameters.DateDue) {
+		c.JSON(400, gin.H{"status": "Incorrect Date format, Date should be in DD-MMM-YYYY format, e.g., 27-Aug-2024"})
+		return
+	}
+
+	// If the due date is less than the lent date, reject with 400
+	if convertDateToEpoch(lendABookParameters.DateLent) > convertDateToEpoch(lendABookParameters.DateDue) {
+		c.JSON(400, gin.H{"status": "Due date cannot be less than Lent date"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID
+	// Result is scanned into the variable, checkResult
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, lendABookParameters.BookID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingBook.Scan(&checkResult)
+
+	// If the length of checkResult is 0, the query returned no result, so there is no book by that ID, reject with 404
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + lendABookParameters.BookID + " exists"})
+		return
+	}
+
+	// Check if the Book already has a loan which is not returned
+	// If yes, the book is already lent out, reject with 403
+	queryToCheckExistingLoan := `SELECT ID FROM BOOKLOANS WHERE BOOKID=$1 AND DATERETURNED = 0;`
+	resultToCheckExistingLoan := db.QueryRow(queryToCheckExistingLoan, lendABookParameters.BookID)
+	var checkLoan string
+	resultToCheckExistingLoan.Scan(&checkLoan)
+	if len(checkLoan) > 0 {
+		c.JSON(403, gin.H{"status": "Book with ID, " + lendABookParameters.BookID + " is already lent out"})
+		return
+	}
+
+	// Add the loan, we convert the supplied dates in DD-MMM-YYYY format into EpochTime before inserting
+	generatedID := uniqueIDGenerator()
+	queryToLendABook := `INSERT INTO BOOKLOANS (ID, BOOKID, BORROWER, DATELENT, DATEDUE, DATERETURNED) VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err = db.Exec(queryToLendABook, generatedID, lendABookParameters.BookID, sanitizeString(lendABookParameters.Borrower),
+		convertDateToEpoch(lendABookParameters.DateLent), convertDateToEpoch(lendABookParameters.DateDue), 0)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Book, " + lendABookParameters.BookID + " lent to " + sanitizeString(lendABookParameters.Borrower) + ".", "loanID": generatedID})
+
+}
+
+// Defining JSON body for returnABook(). It requires 2 JSON key's bookID, date.
+type ReturnABookParameters struct {
+	BookID string `json:"bookID" binding:"required"`
+	Date   string `json:"date" binding:"required"`
+}
+
+// Returns a lent Book by updating the DATERETURNED column of its current loan
+func returnABook(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, ReturnABookParameters
+	var returnABookParameters ReturnABookParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&returnABookParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Checks if the supplied date is in DD-MMM-YYYY format
+	if !checkDateFormat(returnABookParameters.Date) {
+		c.JSON(400, gin.H{"status": "Incorrect Date format, Date should be in DD-MMM-YYYY format, e.g., 27-Aug-2024"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID
+	// Result is scanned into the variable, checkResult
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, returnABookParameters.BookID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingBook.Scan(&checkResult)
+
+	// If the length of checkResult is 0, the query returned no result, so there is no book by that ID, reject with 404
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + returnABookParameters.BookID + " exists"})
+		return
+	}
+
+	// Get the Book's current loan, which is the loan that is not returned
+	queryToGetCurrentLoan := `SELECT ID, DATELENT FROM BOOKLOANS WHERE BOOKID=$1 AND DATERETURNED = 0;`
+	resultToGetCurrentLoan := db.QueryRow(queryToGetCurrentLoan, returnABookParameters.BookID)
+
+	// Defining a struct to hold the data queried by the query and scanning into it
+	type CurrentLoan struct {
+		loanID   string
+		dateLent int
+	}
+	var currentLoan CurrentLoan
+	resultToGetCurrentLoan.Scan(&currentLoan.loanID, &currentLoan.dateLent)
+
+	// If there is no current loan, the book is not lent out, reject with 403
+	if len(currentLoan.loanID) == 0 {
+		c.JSON(403, gin.H{"status": "Book with ID, " + returnABookParameters.BookID + " is not lent out"})
+		return
+	}
+
+	// If the returned date is less than the lent date, reject with 400
+	if currentLoan.dateLent > convertDateToEpoch(returnABookParameters.Date) {
+		c.JSON(400, gin.H{"status": "Returned date cannot be less than Lent date"})
+		return
+	}
+
+	// Update the DATERETURNED, the loan is kept so the Book's loan history is preserved
+	queryToReturnABook := `UPDATE BOOKLOANS SET DATERETURNED = $1 WHERE ID = $2;`
+	_, err = db.Exec(queryToReturnABook, convertDateToEpoch(returnABookParameters.Date), currentLoan.loanID)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Book, " + returnABookParameters.BookID + " returned."})
+
+}
+
+// Defining JSON body for getLoanHistory(). It requires 1 Query Parameter bookID.
+type GetLoanHistoryParameters struct {
+	BookID string `form:"bookID" binding:"required"`
+}
+
+// Returns all the loans of a Book, including the returned ones
+func getLoanHistory(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetLoanHistoryParameters
+	var getLoanHistoryParameters GetLoanHistoryParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.Bind(&getLoanHistoryParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID
+	// Result is scanned into the variable, checkResult
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, getLoanHistoryParameters.BookID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingBook.Scan(&checkResult)
+
+	// If the length of checkResult is 0, the query returned no result, so there is no book by that ID, reject with 404
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book by ID, " + getLoanHistoryParameters.BookID + " exists."})
+		return
+	}
+
+	// Query the DB and result is held into the variable, result
+	queryToGetLoanHistory := `SELECT ID, BORROWER, DATELENT, DATEDUE, DATERETURNED FROM BOOKLOANS WHERE BOOKID = $1 ORDER BY DATELENT;`
+	result, error := db.Query(queryToGetLoanHistory, getLoanHistoryParameters.BookID)
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetLoanDetails struct {
+		ID           string `json:"loanID"`
+		Borrower     string `json:"borrower"`
+		DateLent     string `json:"dateLent"`
+		DateDue      string `json:"dateDue"`
+		DateReturned string `json:"dateReturned"`
+	}
+
+	// Creating a slice from the struct
+	getLoanDetails := []GetLoanDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and variables to hold the dates in Epoch time
+		GetLoanDetails := GetLoanDetails{}
+		var dateLent, dateDue, dateReturned int
+
+		// Scan the results into the struct
+		result.Scan(&GetLoanDetails.ID, &GetLoanDetails.Borrower, &dateLent, &dateDue, &dateReturned)
+
+		//Converting the dates into DD-MMM-YYYY format
+		GetLoanDetails.DateLent = convertEpochToDate(dateLent)
+		GetLoanDetails.DateDue = convertEpochToDate(dateDue)
+		GetLoanDetails.DateReturned = convertEpochToDate(dateReturned)
+
+		// Append to the slice
+		getLoanDetails = append(getLoanDetails, GetLoanDetails)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"bookID": getLoanHistoryParameters.BookID, "loanHistory": getLoanDetails})
+
+}
+
+// Returns all the loans which are not returned
+func getOutstandingLoans(c *gin.Context) {
+
+	// Query for loans of the user's Books which are not returned
+	queryToGetOutstandingLoans := `SELECT BOOKLOANS.ID, BOOKLOANS.BOOKID, BOOKMANAGEMENT.BOOK, BOOKMANAGEMENT.AUTHOR, BOOKLOANS.BORROWER, BOOKLOANS.DATELENT, BOOKLOANS.DATEDUE
+	FROM BOOKLOANS INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKLOANS.BOOKID WHERE BOOKLOANS.DATERETURNED = 0 AND BOOKMANAGEMENT.USERID = $1
+	ORDER BY BOOKLOANS.DATEDUE;`
+
+	getLoans(c, queryToGetOutstandingLoans, "outstandingLoans", currentUserID(c))
+
+}
+
+// Returns all the loans which are not returned and are past their due date
+func getOverdueLoans(c *gin.Context) {
+
+	// Query for loans of the user's Books which are not returned and whose due date is before today
+	queryToGetOverdueLoans := `SELECT BOOKLOANS.ID, BOOKLOANS.BOOKID, BOOKMANAGEMENT.BOOK, BOOKMANAGEMENT.AUTHOR, BOOKLOANS.BORROWER, BOOKLOANS.DATELENT, BOOKLOANS.DATEDUE
+	FROM BOOKLOANS INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKLOANS.BOOKID WHERE BOOKLOANS.DATERETURNED = 0 AND BOOKLOANS.DATEDUE < $1
+	AND BOOKMANAGEMENT.USERID = $2 ORDER BY BOOKLOANS.DATEDUE;`
+
+	getLoans(c, queryToGetOverdueLoans, "overdueLoans", todaysDateInEpoch(), currentUserID(c))
+
+}
+
+// Runs a query for loans which are not returned and responds with them under the supplied key
+// Used by getOutstandingLoans() and getOverdueLoans(), which only differ in their query
+func getLoans(c *gin.Context, queryToGetLoans string, responseKey string, queryParameters ...any) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	result, error := db.Query(queryToGetLoans, queryParameters...)
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetLoanDetails struct {
+		ID          string `json:"loanID"`
+		BookID      string `json:"bookID"`
+		Book        string `json:"book"`
+		Author      string `json:"author"`
+		Borrower    string `json:"borrower"`
+		DateLent    string `json:"dateLent"`
+		DateDue     string `json:"dateDue"`
+		DaysOverdue int    `json:"daysOverdue"`
+	}
+
+	// Creating a slice from the struct
+	getLoanDetails := []GetLoanDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and variables to hold the dates in Epoch time
+		GetLoanDetails := GetLoanDetails{}
+		var dateLent, dateDue int
+
+		// Scan the results into the struct
+		result.Scan(&GetLoanDetails.ID, &GetLoanDetails.BookID, &GetLoanDetails.Book, &GetLoanDetails.Author, &GetLoanDetails.Borrower, &dateLent, &dateDue)
+
+		// Calculate the days overdue by subtracting the Due date in Epoch from today in Epoch and dividing it by 86400
+		// If the loan is not yet due, it stays at 0
+		if todaysDateInEpoch() > dateDue {
+			GetLoanDetails.DaysOverdue = (todaysDateInEpoch() - dateDue) / 86400
+		}
+
+		//Converting the dates into DD-MMM-YYYY format
+		GetLoanDetails.DateLent = convertEpochToDate(dateLent)
+		GetLoanDetails.DateDue = convertEpochToDate(dateDue)
+
+		// Append to the slice
+		getLoanDetails = append(getLoanDetails, GetLoanDetails)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{responseKey: getLoanDetails})
+
+}
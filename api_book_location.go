@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"math"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defining JSON body for getBooksByLocation(). It takes 3 optional Query Parameters room, shelf, box, atleast one of them is required.
+type GetBooksByLocationParameters struct {
+	Room  string `form:"room"`
+	Shelf string `form:"shelf"`
+	Box   string `form:"box"`
+}
+
+// Returns all Books whose physical copy is in a specific room, shelf or box
+func getBooksByLocation(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetBooksByLocationParameters
+	var getBooksByLocationParameters GetBooksByLocationParameters
+
+	// Bind to the struct's members. If none of the members are supplied, its rejected with 400
+	if c.Bind(&getBooksByLocationParameters) != nil || (getBooksByLocationParameters.Room == "" && getBooksByLocationParameters.Shelf == "" && getBooksByLocationParameters.Box == "") {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide atleast one of room, shelf or box"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	// A location parameter which is not supplied, matches every value
+	queryToGetBooksByLocation := `SELECT BOOKMANAGEMENT.ID, BOOKMANAGEMENT.BOOK, BOOKMANAGEMENT.AUTHOR, BOOKOWNERSHIP.ROOM, BOOKOWNERSHIP.SHELF, BOOKOWNERSHIP.BOX, BOOKOWNERSHIP.CONDITION
+	FROM BOOKOWNERSHIP INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKOWNERSHIP.BOOKID
+	WHERE ($1 = '' OR BOOKOWNERSHIP.ROOM = $1) AND ($2 = '' OR BOOKOWNERSHIP.SHELF = $2) AND ($3 = '' OR BOOKOWNERSHIP.BOX = $3) AND BOOKMANAGEMENT.USERID = $4;`
+	result, error := db.Query(queryToGetBooksByLocation, sanitizeString(getBooksByLocationParameters.Room), sanitizeString(getBooksByLocationParameters.Shelf),
+		sanitizeString(getBooksByLocationParameters.Box), currentUserID(c))
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetBookDetails struct {
+		ID        string `json:"id"`
+		Book      string `json:"book"`
+		Author    string `json:"author"`
+		Room      string `json:"room"`
+		Shelf     string `json:"shelf"`
+		Box       string `json:"box"`
+		Condition string `json:"condition"`
+	}
+
+	// Creating a slice from the struct
+	getBookDetails := []GetBookDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct
+		GetBookDetails := GetBookDetails{}
+
+		// Scan the results into the struct
+		result.Scan(&GetBookDetails.ID, &GetBookDetails.Book, &GetBookDetails.Author, &GetBookDetails.Room, &GetBookDetails.Shelf, &GetBookDetails.Box, &GetBookDetails.Condition)
+
+		// Append to the slice
+		getBookDetails = append(getBookDetails, GetBookDetails)
+	}
+
+	// If there is no result, means, no book is in that location. Return a 404
+	if len(getBookDetails) == 0 {
+		c.JSON(404, gin.H{"status": "No book found in that location."})
+		return
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"booksInLocation": getBookDetails})
+
+}
+
+// Returns the total value of the library, i.e. the sum of the price paid for all the Books, with a breakdown by room
+func getLibraryValue(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB for the value of the Books in each room, result is held into the variable, result
+	// Only the user's Books which still exist in BOOKMANAGEMENT are counted
+	queryToGetValueByRoom := `SELECT BOOKOWNERSHIP.ROOM, COUNT(*), TOTAL(BOOKOWNERSHIP.PRICEPAID) FROM BOOKOWNERSHIP
+	INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKOWNERSHIP.BOOKID WHERE BOOKMANAGEMENT.USERID = $1
+	GROUP BY BOOKOWNERSHIP.ROOM ORDER BY BOOKOWNERSHIP.ROOM;`
+	result, error := db.Query(queryToGetValueByRoom, currentUserID(c))
+	// If there's any error when querying, return it
+	if error != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetRoomValue struct {
+		Room  string  `json:"room"`
+		Books int     `json:"books"`
+		Value float64 `json:"value"`
+	}
+
+	// Creating a slice from the struct and variables to hold the totals
+	getRoomValue := []GetRoomValue{}
+	var totalBooks int
+	var totalValue float64
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct
+		GetRoomValue := GetRoomValue{}
+
+		// Scan the results into the struct
+		result.Scan(&GetRoomValue.Room, &GetRoomValue.Books, &GetRoomValue.Value)
+
+		// Adding to the totals and rounding the value to 2 decimal places
+		totalBooks = totalBooks + GetRoomValue.Books
+		totalValue = totalValue + GetRoomValue.Value
+		GetRoomValue.Value = math.Round(GetRoomValue.Value*100) / 100
+
+		// Append to the slice
+		getRoomValue = append(getRoomValue, GetRoomValue)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"totalValue": math.Round(totalValue*100) / 100, "booksWithOwnershipDetails": totalBooks, "valueByRoom": getRoomValue})
+
+}
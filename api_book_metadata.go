@@ -0,0 +1,365 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Base URL of the Open Library compatible service used to look up Book metadata, set by METADATA_SERVICE_URL
+var metadataServiceURL = strings.TrimSuffix(getTextSettingFromEnvironment("METADATA_SERVICE_URL", "https://openlibrary.org"), "/")
+
+// Base URL of the service the covers are served from, set by METADATA_COVERS_URL, only covers from here can be saved
+var metadataCoversURL = strings.TrimSuffix(getTextSettingFromEnvironment("METADATA_COVERS_URL", "https://covers.openlibrary.org"), "/")
+
+// Days a lookup is cached for, set by METADATA_CACHE_DAYS, defaults to 30
+var metadataCacheDays = getSettingFromEnvironment("METADATA_CACHE_DAYS", 30)
+
+// Number of suggestions returned by a lookup and the number of subjects kept for each of them
+const metadataSuggestionsCount = 5
+const metadataSubjectsCount = 10
+
+// Client used to call the metadata service, so a slow service cannot hold up a request for long
+var metadataClient = &http.Client{Timeout: 10 * time.Second}
+
+// Client used to download covers, it only follows redirects which stay on the covers service, so the server cannot be sent anywhere else
+var coversClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(request *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("the covers service redirected too many times")
+		}
+		if !strings.HasPrefix(request.URL.String(), metadataCoversURL+"/") {
+			return errors.New("the covers service redirected to " + request.URL.Host)
+		}
+		return nil
+	},
+}
+
+// Metadata suggested for a Book by the metadata service
+type MetadataSuggestion struct {
+	Book            string   `json:"book"`
+	Author          string   `json:"author"`
+	ISBN            string   `json:"isbn"`
+	TotalPages      int      `json:"totalPages"`
+	PublicationYear int      `json:"publicationYear"`
+	CoverURL        string   `json:"coverUrl"`
+	Subjects        []string `json:"subjects"`
+}
+
+// Defining Query Parameters for lookupBookMetadata(). It requires either isbn, or book and author.
+type LookupBookMetadataParameters struct {
+	BookName   string `form:"book"`
+	AuthorName string `form:"author"`
+	ISBN       string `form:"isbn"`
+}
+
+// Looks up suggested metadata for a Book by its ISBN, or by its name and author, from the metadata service
+// Nothing is saved, the suggestions can be accepted or changed and then saved with applyBookMetadata()
+// Lookups are cached, so looking up the same Book again does not call the service
+func lookupBookMetadata(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, LookupBookMetadataParameters
+	var lookupBookMetadataParameters LookupBookMetadataParameters
+
+	// Bind to the struct's members. Either the ISBN, or the Book and author are required, if not, its rejected with 400
+	if c.ShouldBindQuery(&lookupBookMetadataParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+	isbn := strings.ReplaceAll(strings.ReplaceAll(lookupBookMetadataParameters.ISBN, "-", ""), " ", "")
+	bookName, authorName := sanitizeString(lookupBookMetadataParameters.BookName), sanitizeString(lookupBookMetadataParameters.AuthorName)
+	if isbn == "" && (bookName == "" || authorName == "") {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide an isbn, or a book and an author"})
+		return
+	}
+
+	// Build the search, by ISBN if its provided, else by the Book and author
+	searchParameters := url.Values{}
+	searchParameters.Set("fields", "title,author_name,isbn,number_of_pages_median,first_publish_year,cover_i,subject")
+	searchParameters.Set("limit", strconv.Itoa(metadataSuggestionsCount))
+	if isbn != "" {
+		searchParameters.Set("q", "isbn:"+isbn)
+	} else {
+		searchParameters.Set("title", bookName)
+		searchParameters.Set("author", authorName)
+	}
+	searchURL := metadataServiceURL + "/search.json?" + searchParameters.Encode()
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the search is cached and the cache has not expired
+	queryToGetCachedLookup := `SELECT RESPONSE FROM METADATACACHE WHERE LOOKUP = $1 AND DATEFETCHED > $2;`
+	var response string
+	db.QueryRow(queryToGetCachedLookup, searchURL, time.Now().AddDate(0, 0, -metadataCacheDays).Unix()).Scan(&response)
+	cached := response != ""
+
+	// If not, call the metadata service, if it cannot be reached or fails, reject with 502
+	if !cached {
+		serviceResponse, err := metadataClient.Get(searchURL)
+		if err != nil {
+			c.JSON(502, gin.H{"status": "Could not reach the metadata service"})
+			return
+		}
+		defer serviceResponse.Body.Close()
+		responseBody, err := io.ReadAll(io.LimitReader(serviceResponse.Body, 5<<20))
+		if err != nil || serviceResponse.StatusCode != 200 {
+			c.JSON(502, gin.H{"status": "The metadata service returned an error, status " + strconv.Itoa(serviceResponse.StatusCode)})
+			return
+		}
+		response = string(responseBody)
+	}
+
+	// Read the search results
+	type SearchResults struct {
+		Docs []struct {
+			Title               string   `json:"title"`
+			AuthorName          []string `json:"author_name"`
+			ISBN                []string `json:"isbn"`
+			NumberOfPagesMedian int      `json:"number_of_pages_median"`
+			FirstPublishYear    int      `json:"first_publish_year"`
+			CoverID             int      `json:"cover_i"`
+			Subject             []string `json:"subject"`
+		} `json:"docs"`
+	}
+	var searchResults SearchResults
+	if json.Unmarshal([]byte(response), &searchResults) != nil {
+		c.JSON(502, gin.H{"status": "The metadata service returned a response which could not be read"})
+		return
+	}
+
+	// Cache the response, only once it is known to be readable
+	if !cached {
+		queryToCacheLookup := `INSERT OR REPLACE INTO METADATACACHE (LOOKUP, RESPONSE, DATEFETCHED) VALUES ($1, $2, $3);`
+		db.Exec(queryToCacheLookup, searchURL, response, time.Now().Unix())
+	}
+
+	// Turn each search result into a suggestion
+	suggestions := []MetadataSuggestion{}
+	for _, searchResult := range searchResults.Docs {
+
+		suggestion := MetadataSuggestion{Book: searchResult.Title, Author: strings.Join(searchResult.AuthorName, ", "), ISBN: isbn,
+			TotalPages: searchResult.NumberOfPagesMedian, PublicationYear: searchResult.FirstPublishYear, Subjects: searchResult.Subject}
+
+		// Suggest the looked up ISBN, else the first ISBN 13 of the result
+		for _, resultISBN := range searchResult.ISBN {
+			if suggestion.ISBN != "" {
+				break
+			}
+			if len(resultISBN) == 13 {
+				suggestion.ISBN = resultISBN
+			}
+		}
+		if searchResult.CoverID > 0 {
+			suggestion.CoverURL = metadataCoversURL + "/b/id/" + strconv.Itoa(searchResult.CoverID) + "-L.jpg"
+		}
+		if len(suggestion.Subjects) > metadataSubjectsCount {
+			suggestion.Subjects = suggestion.Subjects[:metadataSubjectsCount]
+		}
+		if suggestion.Subjects == nil {
+			suggestion.Subjects = []string{}
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	c.JSON(200, gin.H{"cached": cached, "suggestions": suggestions})
+
+}
+
+// Defining JSON body for applyBookMetadata(). It requires 1 JSON key bookID, every other key is optional, only the supplied ones are saved.
+type ApplyBookMetadataParameters struct {
+	BookID          string    `json:"bookID" binding:"required"`
+	ISBN            *string   `json:"isbn"`
+	TotalPages      *int      `json:"totalPages"`
+	PublicationYear *int      `json:"publicationYear"`
+	CoverURL        *string   `json:"coverUrl"`
+	Subjects        *[]string `json:"subjects"`
+}
+
+// Saves metadata for a Book, usually a suggestion from lookupBookMetadata(), as it is or with some values changed
+// The page count updates the Book, the cover is downloaded and saved the same way as uploadCover(), the rest is saved in BOOKMETADATA
+func applyBookMetadata(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, ApplyBookMetadataParameters
+	var applyBookMetadataParameters ApplyBookMetadataParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&applyBookMetadataParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// The page count should be a positive number, and the publication year should be in YYYY format
+	if applyBookMetadataParameters.TotalPages != nil && *applyBookMetadataParameters.TotalPages <= 0 {
+		c.JSON(400, gin.H{"status": "Total pages should be a positive number."})
+		return
+	}
+	if applyBookMetadataParameters.PublicationYear != nil && (*applyBookMetadataParameters.PublicationYear < 0 || *applyBookMetadataParameters.PublicationYear > 9999) {
+		c.JSON(400, gin.H{"status": "Publication year should be in YYYY format."})
+		return
+	}
+
+	// Only covers from the covers service can be saved, so the server cannot be made to download from anywhere else
+	if applyBookMetadataParameters.CoverURL != nil && *applyBookMetadataParameters.CoverURL != "" && !strings.HasPrefix(*applyBookMetadataParameters.CoverURL, metadataCoversURL+"/") {
+		c.JSON(400, gin.H{"status": "Cover URL should be from " + metadataCoversURL})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID, and get its name and author
+	queryToCheckExistingBook := `SELECT ID, BOOK, AUTHOR FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, applyBookMetadataParameters.BookID, currentUserID(c))
+	var checkResult, bookName, authorName string
+	resultToCheckExistingBook.Scan(&checkResult, &bookName, &authorName)
+
+	// If the length of checkResult is 0, the query returned no result, so there is no book by that ID, reject with 404
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + applyBookMetadataParameters.BookID + " exists"})
+		return
+	}
+
+	// The page count is checked the same way as in updateBookDetails(), if it is rejected, nothing is saved
+	if applyBookMetadataParameters.TotalPages != nil {
+		if statusCode, reason := checkTotalPagesChange(db, checkResult, currentUserID(c), bookName, authorName, *applyBookMetadataParameters.TotalPages); reason != "" {
+			c.JSON(statusCode, gin.H{"status": reason})
+			return
+		}
+	}
+
+	// Download the cover first and check that it can be used, so nothing is saved if it cannot be
+	var coverImage []byte
+	var contentType, fileExtension, coverError string
+	var decodedImage image.Image
+	if applyBookMetadataParameters.CoverURL != nil && *applyBookMetadataParameters.CoverURL != "" {
+		coverImage, err = downloadCover(*applyBookMetadataParameters.CoverURL)
+		if err != nil {
+			c.JSON(502, gin.H{"status": "Could not download the cover, " + err.Error()})
+			return
+		}
+		contentType, fileExtension, decodedImage, coverError = checkCoverImage(coverImage)
+		if coverError != "" {
+			c.JSON(415, gin.H{"status": coverError})
+			return
+		}
+	}
+
+	// Update the page count, a finished Book has all its pages read, same as finishABook()
+	if applyBookMetadataParameters.TotalPages != nil {
+		queryToUpdatePages := `UPDATE BOOKMANAGEMENT SET TOTALPAGES = $1, READPAGES = CASE WHEN DATEFINISHED IS NOT 0 THEN $1 ELSE READPAGES END WHERE ID = $2;`
+		if _, err = db.Exec(queryToUpdatePages, *applyBookMetadataParameters.TotalPages, checkResult); err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+	}
+
+	// Save the ISBN, publication year and subjects, COALESCE keeps the existing value for the ones which are not supplied
+	var subjects *string
+	if applyBookMetadataParameters.Subjects != nil {
+		sanitizedSubjects := []string{}
+		for _, subject := range *applyBookMetadataParameters.Subjects {
+			if sanitizedSubject := sanitizeString(subject); sanitizedSubject != "" {
+				sanitizedSubjects = append(sanitizedSubjects, strings.ReplaceAll(sanitizedSubject, "|", ""))
+			}
+		}
+		joinedSubjects := strings.Join(sanitizedSubjects, "|")
+		subjects = &joinedSubjects
+	}
+	queryToAddMetadata := `INSERT OR IGNORE INTO BOOKMETADATA (BOOKID) VALUES ($1);`
+	queryToUpdateMetadata := `UPDATE BOOKMETADATA SET ISBN = COALESCE($1, ISBN), PUBLICATIONYEAR = COALESCE($2, PUBLICATIONYEAR), SUBJECTS = COALESCE($3, SUBJECTS) WHERE BOOKID = $4;`
+	_, err = db.Exec(queryToAddMetadata, checkResult)
+	if err == nil {
+		_, err = db.Exec(queryToUpdateMetadata, sanitizeOptionalString(applyBookMetadataParameters.ISBN), applyBookMetadataParameters.PublicationYear, subjects, checkResult)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// Save the cover and its thumbnail, replacing any existing cover
+	if coverImage != nil {
+		if err = saveCover(db, checkResult, coverImage, contentType, fileExtension, decodedImage); err != nil {
+			c.JSON(500, gin.H{"status": "Could not save the cover image"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "Metadata saved for Book, " + checkResult + ".", "metadata": getBookMetadata(db, checkResult)})
+
+}
+
+// Returns the saved metadata of a Book, or nil if it has none
+func getBookMetadata(db *sql.DB, bookID string) gin.H {
+
+	queryToGetMetadata := `SELECT ISBN, PUBLICATIONYEAR, SUBJECTS FROM BOOKMETADATA WHERE BOOKID = $1;`
+	var isbn, subjects string
+	var publicationYear int
+	if db.QueryRow(queryToGetMetadata, bookID).Scan(&isbn, &publicationYear, &subjects) != nil {
+		return nil
+	}
+
+	subjectList := []string{}
+	if subjects != "" {
+		subjectList = strings.Split(subjects, "|")
+	}
+
+	return gin.H{"isbn": isbn, "publicationYear": publicationYear, "subjects": subjectList}
+
+}
+
+// Downloads a cover image from the covers service, covers larger than the allowed size are rejected
+func downloadCover(coverURL string) ([]byte, error) {
+
+	coverResponse, err := coversClient.Get(coverURL)
+	if err != nil {
+		return nil, err
+	}
+	defer coverResponse.Body.Close()
+
+	if coverResponse.StatusCode != 200 {
+		return nil, errors.New("the covers service returned status " + strconv.Itoa(coverResponse.StatusCode))
+	}
+
+	coverImage, err := io.ReadAll(io.LimitReader(coverResponse.Body, maxCoverSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(coverImage) > maxCoverSize {
+		return nil, errors.New("cover image cannot be larger than 5 MB")
+	}
+
+	return coverImage, nil
+
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"database/sql"
+	"strconv"
+	"unicode/utf8"
 
 	_ "modernc.org/sqlite"
 
@@ -30,6 +32,12 @@ func addNote(c *gin.Context) {
 		return
 	}
 
+	// If the note is longer than MAX_NOTE_LENGTH, reject with 400
+	if utf8.RuneCountInString(sanitizeString(addNoteParameters.Note)) > maxNoteLength {
+		c.JSON(400, gin.H{"status": noteTooLongStatus()})
+		return
+	}
+
 	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
 	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
 	if err != nil {
@@ -40,8 +48,8 @@ func addNote(c *gin.Context) {
 
 	// Check if the BookID exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, addNoteParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, addNoteParameters.BookID, currentUserID(c))
 	var checkResult string
 	resultToCheckExistingBook.Scan(&checkResult)
 
@@ -84,12 +92,19 @@ func addToANote(c *gin.Context) {
 	}
 	defer db.Close()
 
-	// Check if the BookID exists in the DB by querying for the ID
-	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, addNoteParameters.BookID)
+	// Check if the BookID exists in the DB by querying for the ID, and get the length of its note
+	// Result is scanned into the variables, checkResult and noteLength
+	queryToCheckExistingBook := `SELECT ID, LENGTH(COALESCE(NOTES, '')) FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, addNoteParameters.BookID, currentUserID(c))
 	var checkResult string
-	resultToCheckExistingBook.Scan(&checkResult)
+	var noteLength int
+	resultToCheckExistingBook.Scan(&checkResult, &noteLength)
+
+	// If the note would become longer than MAX_NOTE_LENGTH, reject with 400
+	if len(checkResult) > 0 && noteLength+1+utf8.RuneCountInString(sanitizeString(addNoteParameters.Note)) > maxNoteLength {
+		c.JSON(400, gin.H{"status": noteTooLongStatus()})
+		return
+	}
 
 	// If the length of checkResult is greater than 0, means the query returned a result, so there is a book by that ID
 	// Else, its rejected with a 404 as there is no book by that ID
@@ -105,3 +120,10 @@ func addToANote(c *gin.Context) {
 	}
 
 }
+
+// Returns the status for a note which is longer than MAX_NOTE_LENGTH
+func noteTooLongStatus() string {
+
+	return "Note is too long, notes can be at most " + strconv.Itoa(maxNoteLength) + " characters"
+
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported relations between two Books and how they read from the related Book's side
+// e.g. if Book A is a translation-of Book B, then Book B has-translation Book A
+var bookRelationTypes = map[string]string{
+	"translation-of": "has-translation",
+	"sequel-to":      "prequel-of",
+	"companion":      "companion",
+	"recommended-by": "recommended",
+	"referenced-in":  "references",
+}
+
+// A relation of a Book, as seen from that Book's side
+type BookRelation struct {
+	RelationID    string `json:"relationID"`
+	Relation      string `json:"relation"`
+	RelatedBookID string `json:"relatedBookID"`
+	RelatedBook   string `json:"relatedBook"`
+	RelatedAuthor string `json:"relatedAuthor"`
+}
+
+// Defining JSON body for relateBooks(). It requires 3 JSON key's bookID, relatedBookID, relation.
+type RelateBooksParameters struct {
+	BookID        string `json:"bookID" binding:"required"`
+	RelatedBookID string `json:"relatedBookID" binding:"required"`
+	Relation      string `json:"relation" binding:"required"`
+}
+
+// Links a Book to another Book with a typed relation, e.g. Book A is a sequel-to Book B
+func relateBooks(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RelateBooksParameters
+	var relateBooksParameters RelateBooksParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&relateBooksParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Checks if the relation is one of the supported relations
+	if _, supportedRelation := bookRelationTypes[relateBooksParameters.Relation]; !supportedRelation {
+		c.JSON(400, gin.H{"status": "Incorrect relation, relation should be one of translation-of, sequel-to, companion, recommended-by or referenced-in"})
+		return
+	}
+
+	// A Book cannot be related to itself
+	if relateBooksParameters.BookID == relateBooksParameters.RelatedBookID {
+		c.JSON(400, gin.H{"status": "A Book cannot be related to itself"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if both the Books exist in the DB by querying for their IDs, if any of them does not exist, reject with 404
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	for _, bookID := range []string{relateBooksParameters.BookID, relateBooksParameters.RelatedBookID} {
+		resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, bookID, currentUserID(c))
+		var checkResult string
+		resultToCheckExistingBook.Scan(&checkResult)
+		if len(checkResult) == 0 {
+			c.JSON(404, gin.H{"status": "No Book with ID, " + bookID + " exists"})
+			return
+		}
+	}
+
+	// Check if the same relation already exists, if yes, reject with 403
+	// A companion relation reads the same from both sides, so it is checked in both directions
+	queryToCheckExistingRelation := `SELECT ID FROM BOOKRELATIONS WHERE RELATION = $3 AND
+	((BOOKID = $1 AND RELATEDBOOKID = $2) OR (RELATION = 'companion' AND BOOKID = $2 AND RELATEDBOOKID = $1));`
+	resultToCheckExistingRelation := db.QueryRow(queryToCheckExistingRelation, relateBooksParameters.BookID, relateBooksParameters.RelatedBookID, relateBooksParameters.Relation)
+	var checkRelation string
+	resultToCheckExistingRelation.Scan(&checkRelation)
+	if len(checkRelation) > 0 {
+		c.JSON(403, gin.H{"status": "Book, " + relateBooksParameters.BookID + " is already " + relateBooksParameters.Relation + " Book, " + relateBooksParameters.RelatedBookID})
+		return
+	}
+
+	// Add the relation
+	generatedID := uniqueIDGenerator()
+	queryToRelateBooks := `INSERT INTO BOOKRELATIONS (ID, BOOKID, RELATEDBOOKID, RELATION) VALUES ($1, $2, $3, $4);`
+	_, err = db.Exec(queryToRelateBooks, generatedID, relateBooksParameters.BookID, relateBooksParameters.RelatedBookID, relateBooksParameters.Relation)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Relation Added", "relationID": generatedID})
+
+}
+
+// Defining JSON body for deleteRelation(). It requires 1 Query Parameter relationID.
+type DeleteRelationParameters struct {
+	RelationID string `form:"relationID" binding:"required"`
+}
+
+// Deletes a relation between two Books
+func deleteRelation(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, DeleteRelationParameters
+	var deleteRelationParameters DeleteRelationParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.Bind(&deleteRelationParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Delete the relation, if nothing was deleted, the user has no relation by that ID, reject with 404
+	queryToDeleteRelation := `DELETE FROM BOOKRELATIONS WHERE ID = $1 AND BOOKID IN (SELECT ID FROM BOOKMANAGEMENT WHERE USERID = $2);`
+	deleted, err := db.Exec(queryToDeleteRelation, deleteRelationParameters.RelationID, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if rowsDeleted, _ := deleted.RowsAffected(); rowsDeleted == 0 {
+		c.JSON(404, gin.H{"status": "No Relation by ID, " + deleteRelationParameters.RelationID + " exists."})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Relation with ID, " + deleteRelationParameters.RelationID + " deleted."})
+
+}
+
+// Defining JSON body for getReadingTrail(). It requires 1 Query Parameter bookID.
+type GetReadingTrailParameters struct {
+	BookID string `form:"bookID" binding:"required"`
+}
+
+// Returns the trail of Books which led to a Book being picked up
+// Follows the recommended-by and referenced-in relations, from the Book back to where the trail started
+func getReadingTrail(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetReadingTrailParameters
+	var getReadingTrailParameters GetReadingTrailParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.Bind(&getReadingTrailParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the BookID exists in the DB by querying for the ID
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, getReadingTrailParameters.BookID, currentUserID(c))
+	var checkResult string
+	resultToCheckExistingBook.Scan(&checkResult)
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No Book by ID, " + getReadingTrailParameters.BookID + " exists."})
+		return
+	}
+
+	// Walk back from the Book, one Book at a time, keeping track of the visited Books so a loop in the relations ends the trail
+	type ReadingTrailStep struct {
+		BookID string `json:"bookID"`
+		BookRelation
+	}
+	readingTrail := []ReadingTrailStep{}
+	visitedBooks := map[string]bool{checkResult: true}
+	currentBookID := checkResult
+	for {
+		relations, err := getBookRelations(db, currentBookID)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+
+		// Take the first recommended-by or referenced-in relation of the current Book, which was not visited yet
+		var nextStep *ReadingTrailStep
+		for _, relation := range relations {
+			if (relation.Relation == "recommended-by" || relation.Relation == "referenced-in") && !visitedBooks[relation.RelatedBookID] {
+				nextStep = &ReadingTrailStep{BookID: currentBookID, BookRelation: relation}
+				break
+			}
+		}
+		if nextStep == nil {
+			break
+		}
+
+		readingTrail = append(readingTrail, *nextStep)
+		visitedBooks[nextStep.RelatedBookID] = true
+		currentBookID = nextStep.RelatedBookID
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"bookID": checkResult, "readingTrail": readingTrail})
+
+}
+
+// Returns all the relations of a Book, from both sides
+// Relations added from the related Book's side are returned with their inverse, e.g. sequel-to becomes prequel-of
+func getBookRelations(db *sql.DB, bookID string) ([]BookRelation, error) {
+
+	queryToGetRelations := `SELECT BOOKRELATIONS.ID, BOOKRELATIONS.RELATION, 0, BOOKMANAGEMENT.ID, BOOKMANAGEMENT.BOOK, BOOKMANAGEMENT.AUTHOR
+	FROM BOOKRELATIONS INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKRELATIONS.RELATEDBOOKID WHERE BOOKRELATIONS.BOOKID = $1
+	UNION ALL
+	SELECT BOOKRELATIONS.ID, BOOKRELATIONS.RELATION, 1, BOOKMANAGEMENT.ID, BOOKMANAGEMENT.BOOK, BOOKMANAGEMENT.AUTHOR
+	FROM BOOKRELATIONS INNER JOIN BOOKMANAGEMENT ON BOOKMANAGEMENT.ID = BOOKRELATIONS.BOOKID WHERE BOOKRELATIONS.RELATEDBOOKID = $1;`
+	result, err := db.Query(queryToGetRelations, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	relations := []BookRelation{}
+	for result.Next() {
+		relation := BookRelation{}
+		var inverse int
+		result.Scan(&relation.RelationID, &relation.Relation, &inverse, &relation.RelatedBookID, &relation.RelatedBook, &relation.RelatedAuthor)
+		if inverse == 1 {
+			relation.Relation = bookRelationTypes[relation.Relation]
+		}
+		relations = append(relations, relation)
+	}
+
+	return relations, result.Err()
+
+}
@@ -46,8 +46,8 @@ func startABook(c *gin.Context) {
 
 	// Check if the BookID exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, startABookParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, startABookParameters.BookID, currentUserID(c))
 	var checkResult string
 	resultToCheckExistingBook.Scan(&checkResult)
 
@@ -118,8 +118,8 @@ func finishABook(c *gin.Context) {
 
 	// Check if the BookID exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, finishABookParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, finishABookParameters.BookID, currentUserID(c))
 	var checkResult string
 	resultToCheckExistingBook.Scan(&checkResult)
 
@@ -198,8 +198,8 @@ func updateABook(c *gin.Context) {
 
 	// Check if the BookID exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, updateABookParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, updateABookParameters.BookID, currentUserID(c))
 	var checkResult string
 	resultToCheckExistingBook.Scan(&checkResult)
 
@@ -277,8 +277,8 @@ func restartABook(c *gin.Context) {
 
 	// Check if the BookID exists in the DB by querying for the ID
 	// Result is scanned into the variable, checkResult
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1;`
-	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, restartABookParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID=$1 AND USERID=$2;`
+	resultToCheckExistingBook := db.QueryRow(queryToCheckExistingBook, restartABookParameters.BookID, currentUserID(c))
 	var checkResult string
 	resultToCheckExistingBook.Scan(&checkResult)
 
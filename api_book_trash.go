@@ -0,0 +1,329 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Days a Book is kept in the trash before it is purged for good, set by TRASH_RETENTION_DAYS, defaults to 30, 0 keeps them until they are purged
+var trashRetentionDays = getSettingFromEnvironment("TRASH_RETENTION_DAYS", 30)
+
+// Returns the Books in the library's trash, most recently deleted first, with when they will be purged
+func getTrash(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetTrash := `SELECT ID, BOOK, AUTHOR, DATEDELETED FROM BOOKTRASH WHERE USERID = $1 ORDER BY DATEDELETED DESC;`
+	result, err := db.Query(queryToGetTrash, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetTrashedBookDetails struct {
+		ID          string `json:"id"`
+		Book        string `json:"book"`
+		Author      string `json:"author"`
+		DateDeleted string `json:"dateDeleted"`
+		DatePurged  string `json:"datePurged"`
+	}
+
+	// Creating a slice from the struct
+	trashedBooks := []GetTrashedBookDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and a variable to hold the date in Epoch time
+		trashedBook := GetTrashedBookDetails{}
+		var dateDeleted int64
+
+		// Scan the results into the struct
+		result.Scan(&trashedBook.ID, &trashedBook.Book, &trashedBook.Author, &dateDeleted)
+
+		// The dates are returned in RFC 3339 format, the purge date is empty if Books are kept in the trash until they are purged
+		trashedBook.DateDeleted = formatAPIKeyTime(dateDeleted)
+		if trashRetentionDays > 0 {
+			trashedBook.DatePurged = formatAPIKeyTime(dateDeleted + int64(trashRetentionDays)*24*60*60)
+		}
+
+		// Append to the slice
+		trashedBooks = append(trashedBooks, trashedBook)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"trash": trashedBooks})
+
+}
+
+// Defining JSON body for restoreBook(). It requires 1 JSON key bookID.
+type RestoreBookParameters struct {
+	BookID string `json:"bookID" binding:"required"`
+}
+
+// Moves a Book from the trash back into the library, with all its details
+func restoreBook(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RestoreBookParameters
+	var restoreBookParameters RestoreBookParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&restoreBookParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the Book is in the library's trash, if not, reject with 404
+	queryToCheckTrashedBook := `SELECT ID, BOOK, AUTHOR FROM BOOKTRASH WHERE ID = $1 AND USERID = $2;`
+	var bookID, bookName, authorName string
+	db.QueryRow(queryToCheckTrashedBook, restoreBookParameters.BookID, currentUserID(c)).Scan(&bookID, &bookName, &authorName)
+	if len(bookID) == 0 {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + restoreBookParameters.BookID + " is in the trash"})
+		return
+	}
+
+	// The same Book by the same author could have been added again since it was deleted, if so, reject with 403
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND USERID=$3;`
+	var existingBookID string
+	db.QueryRow(queryToCheckExistingBook, bookName, authorName, currentUserID(c)).Scan(&existingBookID)
+	if len(existingBookID) > 0 {
+		c.JSON(403, gin.H{"status": "Book, " + bookName + " by " + authorName + " already exists"})
+		return
+	}
+
+	// The Book is copied back and deleted from the trash in a single transaction, so it is never lost or in both
+	transaction, err := db.Begin()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer transaction.Rollback()
+
+	queryToRestoreBook := `INSERT INTO BOOKMANAGEMENT (ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID, VERSION)
+	SELECT ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID, VERSION FROM BOOKTRASH WHERE ID = $1;`
+	queryToDeleteTrashedBook := `DELETE FROM BOOKTRASH WHERE ID = $1;`
+	if _, err = transaction.Exec(queryToRestoreBook, bookID); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if _, err = transaction.Exec(queryToDeleteTrashedBook, bookID); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if err = transaction.Commit(); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Book with ID, " + bookID + " restored.", "bookID": bookID})
+
+}
+
+// Defining JSON body for purgeBook(). It requires 1 Query Parameter bookID.
+type PurgeBookParameters struct {
+	BookID string `form:"bookID" binding:"required"`
+}
+
+// Deletes a Book in the trash for good, with all its details, it cannot be restored after this
+func purgeBook(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, PurgeBookParameters
+	var purgeBookParameters PurgeBookParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&purgeBookParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the Book is in the library's trash, if not, reject with 404, Books in the library have to be deleted first
+	queryToCheckTrashedBook := `SELECT ID FROM BOOKTRASH WHERE ID = $1 AND USERID = $2;`
+	var bookID string
+	err = db.QueryRow(queryToCheckTrashedBook, purgeBookParameters.BookID, currentUserID(c)).Scan(&bookID)
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + purgeBookParameters.BookID + " is in the trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// If the Book could not be purged, reject with 500, and if it was purged or restored by another request in the meantime, reject with 404
+	purged, err := deleteTrashedBook(db, bookID, nil)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if !purged {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + bookID + " is in the trash"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Book with ID, " + bookID + " purged."})
+
+}
+
+// Deletes a Book from the trash along with its loans, location, cover, genres, relations, metadata and the record of when it was added
+// Everything is deleted in a single transaction, so a Book is never left partly purged, and its cover files are only removed once it is purged
+// Purges made by the server itself pass their audit entries, which are added as the system user in the same transaction
+// Returns FALSE if the Book is not in the trash
+func deleteTrashedBook(db *sql.DB, bookID string, systemAuditEntries []auditEntry) (bool, error) {
+
+	// Get the Book's cover files before its row is deleted
+	queryToGetCover := `SELECT FILENAME, THUMBNAILFILENAME FROM BOOKCOVERS WHERE BOOKID = $1;`
+	var coverFileName, thumbnailFileName string
+	db.QueryRow(queryToGetCover, bookID).Scan(&coverFileName, &thumbnailFileName)
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer transaction.Rollback()
+
+	queryToDeleteTrashedBook := `DELETE FROM BOOKTRASH WHERE ID=$1;`
+	deleted, err := transaction.Exec(queryToDeleteTrashedBook, bookID)
+	if err != nil {
+		return false, err
+	}
+	if rowsDeleted, _ := deleted.RowsAffected(); rowsDeleted == 0 {
+		return false, nil
+	}
+
+	// Delete the Book's loans, location and ownership details, cover, genres, relations from both sides, metadata and the record of when it was added
+	queriesToDeleteBookDetails := []string{
+		`DELETE FROM BOOKLOANS WHERE BOOKID=$1;`,
+		`DELETE FROM BOOKOWNERSHIP WHERE BOOKID=$1;`,
+		`DELETE FROM BOOKCOVERS WHERE BOOKID=$1;`,
+		`DELETE FROM BOOKGENRES WHERE BOOKID=$1;`,
+		`DELETE FROM BOOKRELATIONS WHERE BOOKID=$1 OR RELATEDBOOKID=$1;`,
+		`DELETE FROM BOOKMETADATA WHERE BOOKID=$1;`,
+		`DELETE FROM BOOKADDITIONS WHERE BOOKID=$1;`,
+	}
+	for _, queryToDeleteBookDetails := range queriesToDeleteBookDetails {
+		if _, err = transaction.Exec(queryToDeleteBookDetails, bookID); err != nil {
+			return false, err
+		}
+	}
+	if len(systemAuditEntries) > 0 {
+		if err = insertAuditEntries(transaction, systemUserID, "", "DELETE", "/purgeBook", systemAuditEntries); err != nil {
+			return false, err
+		}
+	}
+	if err = transaction.Commit(); err != nil {
+		return false, err
+	}
+
+	// Delete the Book's cover and its thumbnail
+	if coverFileName != "" {
+		os.Remove(filepath.Join(coversDirectory, coverFileName))
+		os.Remove(filepath.Join(coversDirectory, thumbnailFileName))
+	}
+
+	return true, nil
+
+}
+
+// Purges the Books which have been in the trash for longer than TRASH_RETENTION_DAYS, once when the server starts and then every hour
+// Runs for as long as the server does, so it is started from main() in its own goroutine
+func schedulePurges() {
+
+	if trashRetentionDays < 1 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := purgeExpiredBooks(); err != nil {
+			log.Println("Could not purge the trash, ", err)
+		}
+		<-ticker.C
+	}
+
+}
+
+// Purges the Books in every library's trash which were deleted more than TRASH_RETENTION_DAYS ago
+func purgeExpiredBooks() error {
+
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The IDs and libraries are read first, as deleting a Book runs its own queries
+	queryToGetExpiredBooks := `SELECT ID, USERID FROM BOOKTRASH WHERE DATEDELETED < $1;`
+	result, err := db.Query(queryToGetExpiredBooks, time.Now().Unix()-int64(trashRetentionDays)*24*60*60)
+	if err != nil {
+		return err
+	}
+	expiredBooks := []auditEntry{}
+	for result.Next() {
+		var expiredBook auditEntry
+		result.Scan(&expiredBook.bookID, &expiredBook.libraryID)
+		expiredBooks = append(expiredBooks, expiredBook)
+	}
+	result.Close()
+	if err = result.Err(); err != nil {
+		return err
+	}
+
+	// Each purge is recorded in the library's audit log, with the Book's state in the trash before it and no state after it
+	// A Book which cannot be purged is left for the next run, the others are still purged
+	var purgeErr error
+	for _, expiredBook := range expiredBooks {
+		expiredBook.stateBefore = getBookState(db, expiredBook.bookID, expiredBook.libraryID)
+		if _, err = deleteTrashedBook(db, expiredBook.bookID, []auditEntry{expiredBook}); err != nil {
+			purgeErr = err
+		}
+	}
+
+	return purgeErr
+
+}
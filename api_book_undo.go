@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hours a change can be undone for after it is made, set by UNDO_WINDOW_HOURS, defaults to 24, 0 turns off undo
+var undoWindowHours = getSettingFromEnvironment("UNDO_WINDOW_HOURS", 24)
+
+// Tables of the state of a Book which changes are undone in, by their name in the state
+// The Book, its trash entry and its details have a single row found by the key column, genres, loans and relations have a list of rows
+var bookStateTables = map[string]struct {
+	Table     string
+	KeyColumn string
+}{
+	"book":      {"BOOKMANAGEMENT", "ID"},
+	"trash":     {"BOOKTRASH", "ID"},
+	"ownership": {"BOOKOWNERSHIP", "BOOKID"},
+	"metadata":  {"BOOKMETADATA", "BOOKID"},
+	"addition":  {"BOOKADDITIONS", "BOOKID"},
+	"genres":    {"BOOKGENRES", ""},
+	"loans":     {"BOOKLOANS", ""},
+	"relations": {"BOOKRELATIONS", ""},
+}
+
+// Column names in a state are only used in queries if they look like column names
+var stateColumnRegex = regexp.MustCompile(`^[A-Z_]+$`)
+
+// Reason a change cannot be undone when what it did has been changed again
+const changedSinceReason = "The Book has changed since, undo the later changes to it first"
+
+// A query which reverses part of a change
+type undoOperation struct {
+	Query      string
+	Parameters []any
+}
+
+// Works out the queries which reverse a change, from the Book's state before and after it, and checks that the Book's current state still has what the change did
+// Only what the change did is reversed, so a change can be undone even if other details of the Book have changed since
+// Returns the queries, the details of the Book they change, and if the change cannot be undone, the reason
+func planUndo(stateBefore string, stateAfter string, currentState []byte) ([]undoOperation, []string, string) {
+
+	if stateAfter == "" {
+		return nil, nil, "The change deleted the Book for good, it cannot be undone"
+	}
+	before, after, current := decodeBookState([]byte(stateBefore)), decodeBookState([]byte(stateAfter)), decodeBookState(currentState)
+
+	// Cover images are files, which are not kept in the state
+	if !sameStateValue(before["cover"], after["cover"]) {
+		return nil, nil, "Cover changes cannot be undone"
+	}
+
+	deletions, updates, insertions := []undoOperation{}, []undoOperation{}, []undoOperation{}
+	changes := []string{}
+	changedSince := false
+	stateNames := []string{}
+	for stateName := range bookStateTables {
+		stateNames = append(stateNames, stateName)
+	}
+	sort.Strings(stateNames)
+
+	for _, stateName := range stateNames {
+		table := bookStateTables[stateName]
+
+		// Genres, loans and relations, rows the change added are deleted and rows it deleted are added back
+		if table.KeyColumn == "" {
+			rowsBefore, _ := before[stateName].([]any)
+			rowsAfter, _ := after[stateName].([]any)
+			rowsNow, _ := current[stateName].([]any)
+			listChanged := false
+			for _, row := range rowsAfter {
+				if containsStateRow(rowsBefore, row) {
+					continue
+				}
+				if !containsStateRow(rowsNow, row) {
+					changedSince = true
+				}
+				operation, correct := deleteStateRowOperation(table.Table, row)
+				if !correct {
+					return nil, nil, "The change has an incorrect state"
+				}
+				deletions = append(deletions, operation)
+				listChanged = true
+			}
+			for _, row := range rowsBefore {
+				if containsStateRow(rowsAfter, row) {
+					continue
+				}
+				if containsStateRow(rowsNow, row) {
+					changedSince = true
+				}
+				operation, correct := insertStateRowOperation(table.Table, row)
+				if !correct {
+					return nil, nil, "The change has an incorrect state"
+				}
+				insertions = append(insertions, operation)
+				listChanged = true
+			}
+			if listChanged {
+				changes = append(changes, stateName)
+			}
+			continue
+		}
+
+		// The Book, its trash entry and its details, a row the change added is deleted, a row it deleted is added back, and columns it changed are changed back
+		rowBefore, _ := before[stateName].(map[string]any)
+		rowAfter, _ := after[stateName].(map[string]any)
+		rowNow, _ := current[stateName].(map[string]any)
+		switch {
+		case rowBefore == nil && rowAfter == nil:
+			continue
+		case rowBefore == nil:
+			if !sameStateValue(rowAfter, rowNow) {
+				changedSince = true
+			}
+			deletions = append(deletions, undoOperation{`DELETE FROM "` + table.Table + `" WHERE "` + table.KeyColumn + `" = $1;`, []any{sqlStateValue(rowAfter[table.KeyColumn])}})
+			changes = append(changes, stateName)
+		case rowAfter == nil:
+			if rowNow != nil {
+				changedSince = true
+			}
+			operation, correct := insertStateRowOperation(table.Table, rowBefore)
+			if !correct {
+				return nil, nil, "The change has an incorrect state"
+			}
+			insertions = append(insertions, operation)
+			changes = append(changes, stateName)
+		default:
+			changedColumns := []string{}
+			for column, valueBefore := range rowBefore {
+				// The version is not changed back, undoing a change is a change to the Book too
+				if column == "VERSION" {
+					continue
+				}
+				if !sameStateValue(valueBefore, rowAfter[column]) {
+					if rowNow == nil || !sameStateValue(rowAfter[column], rowNow[column]) {
+						changedSince = true
+					}
+					changedColumns = append(changedColumns, column)
+				}
+			}
+			if len(changedColumns) == 0 {
+				continue
+			}
+			sort.Strings(changedColumns)
+			assignments, parameters := []string{}, []any{}
+			for _, column := range changedColumns {
+				if !stateColumnRegex.MatchString(column) {
+					return nil, nil, "The change has an incorrect state"
+				}
+				parameters = append(parameters, sqlStateValue(rowBefore[column]))
+				assignments = append(assignments, `"`+column+`" = $`+strconv.Itoa(len(parameters)))
+				changes = append(changes, stateName+"."+strings.ToLower(column))
+			}
+			parameters = append(parameters, sqlStateValue(rowBefore[table.KeyColumn]))
+			updates = append(updates, undoOperation{`UPDATE "` + table.Table + `" SET ` + strings.Join(assignments, ", ") + ` WHERE "` + table.KeyColumn + `" = $` + strconv.Itoa(len(parameters)) + `;`, parameters})
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil, "The change did not change the Book"
+	}
+
+	// Undoing the change which added a Book deletes it, so nothing added to the Book since can be left behind
+	if before["book"] == nil && before["trash"] == nil {
+		for stateName := range after {
+			if !sameStateValue(after[stateName], current[stateName]) {
+				changedSince = true
+			}
+		}
+	}
+	if changedSince {
+		return nil, changes, changedSinceReason
+	}
+
+	// Rows are deleted before others are added back, so a Book moved between the library and the trash is never in both
+	return slices.Concat(deletions, updates, insertions), changes, ""
+
+}
+
+// Decodes the state of a Book, numbers are kept as they are so they can be compared and written back exactly
+func decodeBookState(state []byte) map[string]any {
+
+	bookState := map[string]any{}
+	if len(state) == 0 {
+		return bookState
+	}
+	decoder := json.NewDecoder(bytes.NewReader(state))
+	decoder.UseNumber()
+	decoder.Decode(&bookState)
+
+	return bookState
+
+}
+
+// Checks if two values of a state are the same, rows are compared column by column
+// The version of a Book is left out, as it goes up with every change, including undoing one
+func sameStateValue(first any, second any) bool {
+
+	for _, value := range []*any{&first, &second} {
+		if row, isRow := (*value).(map[string]any); isRow && row["VERSION"] != nil {
+			rowWithoutVersion := maps.Clone(row)
+			delete(rowWithoutVersion, "VERSION")
+			*value = rowWithoutVersion
+		}
+	}
+	firstJSON, _ := json.Marshal(first)
+	secondJSON, _ := json.Marshal(second)
+
+	return bytes.Equal(firstJSON, secondJSON)
+
+}
+
+// Checks if a list of rows of a state has a row
+func containsStateRow(rows []any, row any) bool {
+
+	return slices.ContainsFunc(rows, func(otherRow any) bool { return sameStateValue(otherRow, row) })
+
+}
+
+// Returns a value of a state as it should be written to the DB, numbers are written as integers when they are whole
+func sqlStateValue(value any) any {
+
+	if number, isNumber := value.(json.Number); isNumber {
+		if integer, err := number.Int64(); err == nil {
+			return integer
+		}
+		decimal, _ := number.Float64()
+		return decimal
+	}
+
+	return value
+
+}
+
+// Returns the columns of a row of a state in order, and FALSE if the row is not a row or any of its columns does not look like a column name
+func stateRowColumns(row any) (map[string]any, []string, bool) {
+
+	rowValues, isRow := row.(map[string]any)
+	if !isRow || len(rowValues) == 0 {
+		return nil, nil, false
+	}
+	columns := []string{}
+	for column := range rowValues {
+		if !stateColumnRegex.MatchString(column) {
+			return nil, nil, false
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	return rowValues, columns, true
+
+}
+
+// Returns the query which adds a row of a state back to its table, and FALSE if the row is incorrect
+func insertStateRowOperation(table string, row any) (undoOperation, bool) {
+
+	rowValues, columns, correct := stateRowColumns(row)
+	if !correct {
+		return undoOperation{}, false
+	}
+	placeholders, parameters := []string{}, []any{}
+	for _, column := range columns {
+		parameters = append(parameters, sqlStateValue(rowValues[column]))
+		placeholders = append(placeholders, "$"+strconv.Itoa(len(parameters)))
+	}
+
+	return undoOperation{`INSERT INTO "` + table + `" ("` + strings.Join(columns, `", "`) + `") VALUES (` + strings.Join(placeholders, ", ") + `);`, parameters}, true
+
+}
+
+// Returns the query which deletes a row of a state from its table, the row is found by all its columns, and FALSE if the row is incorrect
+func deleteStateRowOperation(table string, row any) (undoOperation, bool) {
+
+	rowValues, columns, correct := stateRowColumns(row)
+	if !correct {
+		return undoOperation{}, false
+	}
+	conditions, parameters := []string{}, []any{}
+	for _, column := range columns {
+		parameters = append(parameters, sqlStateValue(rowValues[column]))
+		conditions = append(conditions, `"`+column+`" IS $`+strconv.Itoa(len(parameters)))
+	}
+
+	return undoOperation{`DELETE FROM "` + table + `" WHERE ` + strings.Join(conditions, " AND ") + `;`, parameters}, true
+
+}
+
+// Defining JSON body for getUndoableChanges(). It requires 1 Query Parameter bookID.
+type GetUndoableChangesParameters struct {
+	BookID string `form:"bookID" binding:"required"`
+}
+
+// Returns the changes to a Book made within UNDO_WINDOW_HOURS, newest first, with whether each can be undone now and what undoing it changes back
+func getUndoableChanges(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetUndoableChangesParameters
+	var getUndoableChangesParameters GetUndoableChangesParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&getUndoableChangesParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result, only the changes which have not expired are returned
+	queryToGetChanges := `SELECT AUDITLOG.ID, COALESCE(USERS.USERNAME, CASE AUDITLOG.USERID WHEN $4 THEN $4 ELSE '' END), AUDITLOG.DATE, AUDITLOG.ENDPOINT, AUDITLOG.BEFORE, AUDITLOG.AFTER
+	FROM AUDITLOG LEFT JOIN USERS ON USERS.ID = AUDITLOG.USERID WHERE AUDITLOG.LIBRARYID = $1 AND AUDITLOG.BOOKID = $2 AND AUDITLOG.DATE > $3
+	ORDER BY AUDITLOG.DATE DESC, AUDITLOG.ROWID DESC;`
+	result, err := db.Query(queryToGetChanges, currentUserID(c), getUndoableChangesParameters.BookID, undoWindowStart(), systemUserID)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetUndoableChangeDetails struct {
+		ID          string   `json:"id"`
+		Username    string   `json:"username"`
+		Date        string   `json:"date"`
+		DateExpires string   `json:"dateExpires"`
+		Endpoint    string   `json:"endpoint"`
+		Changes     []string `json:"changes"`
+		Undoable    bool     `json:"undoable"`
+		Reason      string   `json:"reason,omitempty"`
+	}
+
+	// The Book's current state, each change is checked against it
+	currentState := getBookState(db, getUndoableChangesParameters.BookID, currentUserID(c))
+
+	// Creating a slice from the struct
+	undoableChanges := []GetUndoableChangeDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and variables to hold the date in Epoch time and the states
+		undoableChange := GetUndoableChangeDetails{}
+		var date int64
+		var before, after string
+
+		// Scan the results into the struct
+		result.Scan(&undoableChange.ID, &undoableChange.Username, &date, &undoableChange.Endpoint, &before, &after)
+
+		// The dates are returned in RFC 3339 format
+		undoableChange.Date = formatAPIKeyTime(date)
+		undoableChange.DateExpires = formatAPIKeyTime(date + int64(undoWindowHours)*60*60)
+		_, undoableChange.Changes, undoableChange.Reason = planUndo(before, after, currentState)
+		undoableChange.Undoable = undoableChange.Reason == ""
+		if undoableChange.Changes == nil {
+			undoableChange.Changes = []string{}
+		}
+
+		// Append to the slice
+		undoableChanges = append(undoableChanges, undoableChange)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"changes": undoableChanges})
+
+}
+
+// Defining JSON body for undoChange(). It requires 2 JSON key's bookID, changeID.
+type UndoChangeParameters struct {
+	BookID   string `json:"bookID" binding:"required"`
+	ChangeID string `json:"changeID" binding:"required"`
+}
+
+// Undoes a change to a Book made within UNDO_WINDOW_HOURS, by running the queries which reverse what it did
+// If what the change did has been changed again since, its rejected with 409
+func undoChange(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, UndoChangeParameters
+	var undoChangeParameters UndoChangeParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&undoChangeParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the change from the library's audit log, if there is no such change to the Book, reject with 404, and if it has expired, reject with 410
+	queryToGetChange := `SELECT DATE, BEFORE, AFTER FROM AUDITLOG WHERE ID = $1 AND LIBRARYID = $2 AND BOOKID = $3;`
+	var date int64
+	var before, after string
+	err = db.QueryRow(queryToGetChange, undoChangeParameters.ChangeID, currentUserID(c), undoChangeParameters.BookID).Scan(&date, &before, &after)
+	if err != nil {
+		c.JSON(404, gin.H{"status": "No change with ID, " + undoChangeParameters.ChangeID + " to Book, " + undoChangeParameters.BookID + " exists"})
+		return
+	}
+	if date <= undoWindowStart() {
+		c.JSON(410, gin.H{"status": "Changes can only be undone for " + strconv.Itoa(undoWindowHours) + " hours"})
+		return
+	}
+
+	// Work out the queries which reverse the change, if it cannot be undone, reject with 409
+	operations, changes, reason := planUndo(before, after, getBookState(db, undoChangeParameters.BookID, currentUserID(c)))
+	if reason != "" {
+		c.JSON(409, gin.H{"status": reason})
+		return
+	}
+
+	// All the queries are run in a single transaction, so a change is never partly undone
+	transaction, err := db.Begin()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer transaction.Rollback()
+
+	for _, operation := range operations {
+		if _, err = transaction.Exec(operation.Query, operation.Parameters...); err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+	}
+	if err = transaction.Commit(); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Change, " + undoChangeParameters.ChangeID + " undone.", "changes": changes})
+
+}
+
+// Returns the time in Epoch time before which changes can no longer be undone
+func undoWindowStart() int64 {
+
+	return time.Now().Unix() - int64(undoWindowHours)*60*60
+
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Whether changes to a Book need an If-Match header with its version, set by REQUIRE_IF_MATCH, 1 requires it, defaults to 0
+// If-Match is always checked when it is sent
+var requireIfMatch = getSettingFromEnvironment("REQUIRE_IF_MATCH", 0) == 1
+
+// A lock for each Book, so changes to the same Book are made one at a time and each is checked against the version the previous one left
+type bookLocks struct {
+	locks map[string]*bookLock
+	mutex sync.Mutex
+}
+
+// A Book's lock and the number of requests holding or waiting for it, the lock is dropped once there are none
+type bookLock struct {
+	mutex    sync.Mutex
+	requests int
+}
+
+var bookChangeLocks = bookLocks{locks: map[string]*bookLock{}}
+
+// Waits for the Book's lock, and returns the function which releases it
+func (locks *bookLocks) lock(bookID string) func() {
+
+	locks.mutex.Lock()
+	lock, found := locks.locks[bookID]
+	if !found {
+		lock = &bookLock{}
+		locks.locks[bookID] = lock
+	}
+	lock.requests++
+	locks.mutex.Unlock()
+
+	lock.mutex.Lock()
+
+	return func() {
+		lock.mutex.Unlock()
+		locks.mutex.Lock()
+		lock.requests--
+		if lock.requests == 0 {
+			delete(locks.locks, bookID)
+		}
+		locks.mutex.Unlock()
+	}
+
+}
+
+// Returns the ETag of a Book's details, a hash of all of them, so it changes with the Book and with anything else shown with it
+// The details are JSON encoded with their keys sorted, so the same details always have the same ETag
+func bookDetailsETag(bookDetails gin.H) string {
+
+	encodedDetails, _ := json.Marshal(bookDetails)
+	hash := sha256.Sum256(encodedDetails)
+
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+
+}
+
+// Checks if an If-Match or If-None-Match header has an ETag, the header can have a list of ETags separated by commas, or * for any ETag
+// If-None-Match uses the weak comparison, so weak ETags match too, and If-Match the strong comparison, so weak ETags never match
+func headerMatchesETag(header string, etag string, weakComparison bool) bool {
+
+	for _, headerETag := range strings.Split(header, ",") {
+		headerETag = strings.TrimSpace(headerETag)
+		if weakComparison {
+			headerETag = strings.TrimPrefix(headerETag, "W/")
+		}
+		if headerETag == "*" || headerETag == etag {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// Makes changes to the same Book one at a time, the lock is held until the change is recorded in the audit log and the response is sent
+// It runs before recordAuditLog, so the state before a change is read once the previous change to the Book is done
+func lockBook(c *gin.Context) {
+
+	if c.Request.Method == "GET" || c.Request.Method == "HEAD" || c.Request.Method == "OPTIONS" {
+		c.Next()
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	bookID := requestBookID(c, db)
+	db.Close()
+
+	if bookID != "" {
+		unlock := bookChangeLocks.lock(bookID)
+		defer unlock()
+	}
+
+	c.Next()
+
+}
+
+// Checks changes to a Book against its ETag, and moves the Book to its next version once the change is made
+// If If-Match is sent and does not have the Book's current ETag, its rejected with 412, as the Book or something shown with it has changed since the client got it
+// If REQUIRE_IF_MATCH is set and If-Match is not sent, its rejected with 428
+// Requests which are not for a Book in the library, like adding a Book, are not checked
+func checkBookVersion(c *gin.Context) {
+
+	if c.Request.Method == "GET" || c.Request.Method == "HEAD" || c.Request.Method == "OPTIONS" {
+		c.Next()
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// The Book is already locked by lockBook
+	bookID := requestBookID(c, db)
+	if bookID == "" {
+		c.Next()
+		return
+	}
+
+	// Get the Book's current details, Books which are not in the library are left to the endpoint to reject
+	bookDetails, err := getBookDetailsResponse(db, bookID, currentUserID(c))
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if bookDetails == nil {
+		c.Next()
+		return
+	}
+	etag := bookDetailsETag(bookDetails)
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" && requireIfMatch {
+		c.AbortWithStatusJSON(428, gin.H{"status": "Changes to a Book need an If-Match header with the ETag from getBookDetails"})
+		return
+	}
+	if ifMatch != "" && !headerMatchesETag(ifMatch, etag, false) {
+		c.Header("ETag", etag)
+		c.AbortWithStatusJSON(412, gin.H{"status": "Book, " + bookID + " has changed since, its current version is " + strconv.Itoa(bookDetails["version"].(int))})
+		return
+	}
+
+	c.Next()
+
+	// Every change which is made moves the Book to its next version
+	// It runs inside recordAuditLog, so the version is moved on before the response is sent, and the state after the change has the new version
+	if c.Writer.Status() < 400 {
+		queryToUpdateVersion := `UPDATE BOOKMANAGEMENT SET VERSION = VERSION + 1 WHERE ID = $1;`
+		db.Exec(queryToUpdateVersion, bookID)
+	}
+
+}
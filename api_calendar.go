@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defining Query Parameters for getReadingCalendar(). author and shelf are optional, shelf can be reading or finished.
+type GetReadingCalendarParameters struct {
+	Author string `form:"author"`
+	Shelf  string `form:"shelf"`
+}
+
+// Returns the reading timeline as an iCalendar (.ics) feed, which can be subscribed to from a calendar app
+// Each started Book is an all day event from its started date to its finished date, Books being read run through today
+func getReadingCalendar(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetReadingCalendarParameters
+	var getReadingCalendarParameters GetReadingCalendarParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&getReadingCalendarParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Pick the condition for the shelf, only started Books are on the calendar, if the shelf is not supported, reject with 400
+	shelfConditions := map[string]string{"": "DATESTARTED IS NOT 0", "reading": readingBooksCondition, "finished": finishedBooksCondition}
+	condition, supportedShelf := shelfConditions[getReadingCalendarParameters.Shelf]
+	if !supportedShelf {
+		c.JSON(400, gin.H{"status": "Incorrect shelf, shelf should be reading or finished"})
+		return
+	}
+
+	// Only the user's Books are on the calendar, Books by an author are picked out the same way as getBooksByAuthor()
+	condition += ` AND USERID = $1`
+	queryParameters := []any{currentUserID(c)}
+	if getReadingCalendarParameters.Author != "" {
+		condition += ` AND AUTHOR = $2`
+		queryParameters = append(queryParameters, getReadingCalendarParameters.Author)
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetReadingSessions := `SELECT ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, COALESCE(NOTES, '') FROM BOOKMANAGEMENT
+	WHERE ` + condition + ` ORDER BY DATESTARTED;`
+	result, err := db.Query(queryToGetReadingSessions, queryParameters...)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// The calendar is built line by line, each line is folded and ended with CRLF when it is written
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	today := time.Unix(int64(todaysDateInEpoch()), 0).UTC()
+	calendarLines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//GoBookManagementAPI//Reading Timeline//EN", "CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH", "X-WR-CALNAME:Reading Timeline"}
+
+	// Iterating over the results, each Book becomes an event
+	for result.Next() {
+
+		var bookID, book, author, notes string
+		var totalPages, readPages, dateStarted, dateFinished int
+		result.Scan(&bookID, &book, &author, &totalPages, &readPages, &dateStarted, &dateFinished, &notes)
+
+		// Dates are stored at the start of the day, the end date of an all day event is the day after its last day
+		// A Book being read runs through today
+		startDate := time.Unix(int64(dateStarted), 0).UTC()
+		endDate := today
+		summary := "Reading: " + book + " by " + author
+		description := "Started on " + convertEpochToDate(dateStarted) + "."
+		if dateFinished > 0 {
+			endDate = time.Unix(int64(dateFinished), 0).UTC()
+			summary = "Read: " + book + " by " + author
+			description += " Finished on " + convertEpochToDate(dateFinished) + "."
+		}
+		description += " " + strconv.Itoa(readPages) + " of " + strconv.Itoa(totalPages) + " pages read."
+		if endDate.Before(startDate) {
+			endDate = startDate
+		}
+		if notes != "" {
+			description += " " + notes
+		}
+
+		calendarLines = append(calendarLines, "BEGIN:VEVENT", "UID:"+bookID+"@gobookmanagementapi", "DTSTAMP:"+timestamp,
+			"DTSTART;VALUE=DATE:"+startDate.Format("20060102"), "DTEND;VALUE=DATE:"+endDate.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+escapeCalendarText(summary), "DESCRIPTION:"+escapeCalendarText(description), "CATEGORIES:"+escapeCalendarText(author),
+			"TRANSP:TRANSPARENT", "END:VEVENT")
+	}
+	calendarLines = append(calendarLines, "END:VCALENDAR")
+
+	// Fold each line into lines of at most 75 octets, the continuation lines start with a space
+	var calendar strings.Builder
+	for _, calendarLine := range calendarLines {
+		for len(calendarLine) > 75 {
+			cut := 75
+			for cut > 0 && (calendarLine[cut]&0xC0) == 0x80 {
+				cut--
+			}
+			calendar.WriteString(calendarLine[:cut] + "\r\n")
+			calendarLine = " " + calendarLine[cut:]
+		}
+		calendar.WriteString(calendarLine + "\r\n")
+	}
+
+	c.Header("Content-Disposition", `inline; filename="reading-timeline.ics"`)
+	c.Data(200, "text/calendar; charset=utf-8", []byte(calendar.String()))
+
+}
+
+// Escapes the characters which have a meaning in iCalendar text values, backslash, semicolon, comma and new lines
+func escapeCalendarText(text string) string {
+
+	return strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Content type of the Atom feeds
+const atomFeedType = "application/atom+xml; charset=utf-8"
+
+// Number of entries in a feed, if limit is not provided
+const defaultFeedLimit = 20
+
+// Defining Query Parameters for getRecentlyFinishedFeed() and getRecentlyAddedFeed(). limit is optional, it defaults to 20.
+type GetFeedParameters struct {
+	Limit int `form:"limit"`
+}
+
+// Returns an Atom feed of the recently finished Books, the most recently finished first
+func getRecentlyFinishedFeed(c *gin.Context) {
+
+	// Finished Books are picked out the same way as getAllFinishedBooks(), the entry is published when the Book was finished
+	queryToGetFinishedBooks := `SELECT ID, BOOK, AUTHOR, TOTALPAGES, DATESTARTED, DATEFINISHED, COALESCE(NOTES, ''), DATEFINISHED FROM BOOKMANAGEMENT
+	WHERE ` + finishedBooksCondition + ` AND USERID = $2 ORDER BY DATEFINISHED DESC, DATESTARTED DESC LIMIT $1;`
+
+	getBooksFeed(c, "recently-finished", "Recently Finished Books", "/getRecentlyFinishedFeed", queryToGetFinishedBooks)
+
+}
+
+// Returns an Atom feed of the recently added Books, the most recently added first
+// Books added before additions were recorded have no date added, they come last, in the order they were added in
+func getRecentlyAddedFeed(c *gin.Context) {
+
+	// The entry is published when the Book was added
+	queryToGetAddedBooks := `SELECT BOOKMANAGEMENT.ID, BOOK, AUTHOR, TOTALPAGES, DATESTARTED, DATEFINISHED, COALESCE(NOTES, ''), COALESCE(BOOKADDITIONS.DATEADDED, 0)
+	FROM BOOKMANAGEMENT LEFT JOIN BOOKADDITIONS ON BOOKADDITIONS.BOOKID = BOOKMANAGEMENT.ID WHERE USERID = $2
+	ORDER BY COALESCE(BOOKADDITIONS.DATEADDED, 0) DESC, BOOKMANAGEMENT.ROWID DESC LIMIT $1;`
+
+	getBooksFeed(c, "recently-added", "Recently Added Books", "/getRecentlyAddedFeed", queryToGetAddedBooks)
+
+}
+
+// Returns an Atom feed of the user's Books returned by a query, the query takes the limit and the user's ID as its parameters
+// Each Book is an entry, with its title, author, dates and notes, and the Book's ID as the entry ID
+func getBooksFeed(c *gin.Context, feedName string, feedTitle string, feedHref string, queryToGetBooks string) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetFeedParameters
+	var getFeedParameters GetFeedParameters
+
+	// Bind to the struct's members. If the limit is not a number or is negative, its rejected with 400
+	if c.ShouldBindQuery(&getFeedParameters) != nil || getFeedParameters.Limit < 0 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, limit should be a positive number"})
+		return
+	}
+	if getFeedParameters.Limit == 0 {
+		getFeedParameters.Limit = defaultFeedLimit
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	result, err := db.Query(queryToGetBooks, getFeedParameters.Limit, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Each user has their own feed, so the feed ID has the user's ID
+	atomFeed := AtomFeed{ID: "urn:bookmanagement:feed:" + feedName + ":" + currentUserID(c), Title: feedTitle, Author: &AtomAuthor{Name: "Book Management"},
+		Links: []AtomLink{{Rel: "self", Href: feedHref, Type: "application/atom+xml"}}}
+
+	// Iterating over the results, each Book becomes an entry
+	latestUpdate := int64(0)
+	for result.Next() {
+
+		var bookID, book, author, notes string
+		var totalPages, dateStarted, dateFinished int
+		var datePublished int64
+		result.Scan(&bookID, &book, &author, &totalPages, &dateStarted, &dateFinished, &notes, &datePublished)
+
+		// The entry's content has the Book's dates and notes
+		content := strconv.Itoa(totalPages) + " pages."
+		if dateStarted > 0 {
+			content += " Started on " + convertEpochToDate(dateStarted) + "."
+		}
+		if dateFinished > 0 {
+			content += " Finished on " + convertEpochToDate(dateFinished) + "."
+		}
+		if notes != "" {
+			content += " " + notes
+		}
+
+		published := time.Unix(datePublished, 0).UTC().Format(time.RFC3339)
+		atomFeed.Entries = append(atomFeed.Entries, AtomEntry{ID: bookEntryID(bookID), Title: book, Updated: published, Published: published,
+			Authors: []AtomAuthor{{Name: author}}, Content: &AtomContent{Type: "text", Text: content},
+			Links: []AtomLink{{Rel: "alternate", Href: "/getBookDetails?bookID=" + bookID, Type: "application/json"}}})
+
+		if datePublished > latestUpdate {
+			latestUpdate = datePublished
+		}
+	}
+
+	// The feed is updated when its latest entry was, so it only changes when its entries do
+	atomFeed.Updated = time.Unix(latestUpdate, 0).UTC().Format(time.RFC3339)
+
+	writeAtomFeed(c, atomFeed, atomFeedType)
+
+}
+
+// Returns the ID of a Book's feed entries, Book IDs are UUIDs from uniqueIDGenerator() with the hyphens stripped out, so they are put back
+// IDs which are not UUIDs, e.g. from an older import, are used as they are
+func bookEntryID(bookID string) string {
+
+	if len(bookID) != 32 {
+		return "urn:bookmanagement:book:" + bookID
+	}
+
+	return "urn:uuid:" + bookID[0:8] + "-" + bookID[8:12] + "-" + bookID[12:16] + "-" + bookID[16:20] + "-" + bookID[20:32]
+
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Shared secret for HS256 signed tokens, set by JWT_HS256_SECRET, HS256 tokens are not accepted without it
+var jwtSecret = getTextSettingFromEnvironment("JWT_HS256_SECRET", "")
+
+// JWKS file with the public keys for RS256 signed tokens, set by JWT_JWKS_FILE, RS256 tokens are not accepted without it
+// The file is read when the server starts
+var jwtKeysFile = getTextSettingFromEnvironment("JWT_JWKS_FILE", "")
+
+// Issuer and audience the tokens should have, set by JWT_ISSUER and JWT_AUDIENCE, each is only checked if it is set
+var jwtIssuer = getTextSettingFromEnvironment("JWT_ISSUER", "")
+var jwtAudience = getTextSettingFromEnvironment("JWT_AUDIENCE", "")
+
+// Claim with the username of the token's user, set by JWT_USERNAME_CLAIM, defaults to sub
+var jwtUsernameClaim = getTextSettingFromEnvironment("JWT_USERNAME_CLAIM", "sub")
+
+// Public keys read from the JWKS file, by their key ID
+var jwtPublicKeys = map[string]*rsa.PublicKey{}
+
+// A key in a JWKS file, only RSA keys are used
+type JSONWebKey struct {
+	KeyType  string `json:"kty"`
+	KeyID    string `json:"kid"`
+	Use      string `json:"use"`
+	Modulus  string `json:"n"`
+	Exponent string `json:"e"`
+}
+
+// Reads the public keys from the JWKS file, if one is set
+// Called once from main() before the routes are served, if the file cannot be read, stop the server
+func loadJSONWebKeys() {
+
+	if jwtKeysFile == "" {
+		return
+	}
+
+	keysFile, err := os.ReadFile(jwtKeysFile)
+	if err != nil {
+		log.Fatal("Could not read the JWKS file, ", err)
+	}
+	var keySet struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err = json.Unmarshal(keysFile, &keySet); err != nil {
+		log.Fatal("Could not read the JWKS file, ", err)
+	}
+
+	// Keys which are not RSA signing keys are skipped, the modulus and exponent are base64url encoded big endian numbers
+	for _, key := range keySet.Keys {
+		if key.KeyType != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		modulus, modulusErr := base64.RawURLEncoding.DecodeString(key.Modulus)
+		exponent, exponentErr := base64.RawURLEncoding.DecodeString(key.Exponent)
+		if modulusErr != nil || exponentErr != nil || len(exponent) == 0 || len(exponent) > 4 {
+			log.Fatal("Could not read the key, " + key.KeyID + " in the JWKS file")
+		}
+		jwtPublicKeys[key.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	}
+	if len(jwtPublicKeys) == 0 {
+		log.Fatal("No RSA signing keys in the JWKS file")
+	}
+
+}
+
+// Returns the key to check a token's signature with, the shared secret for HS256 or the public key from the JWKS file for RS256
+// A token without a key ID can be used if the JWKS file has only one key
+func getJWTKey(token *jwt.Token) (any, error) {
+
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		return []byte(jwtSecret), nil
+	case jwt.SigningMethodRS256:
+		keyID, _ := token.Header["kid"].(string)
+		if publicKey, found := jwtPublicKeys[keyID]; found {
+			return publicKey, nil
+		}
+		if keyID == "" && len(jwtPublicKeys) == 1 {
+			for _, publicKey := range jwtPublicKeys {
+				return publicKey, nil
+			}
+		}
+		return nil, jwt.ErrTokenUnverifiable
+	}
+
+	return nil, jwt.ErrTokenSignatureInvalid
+
+}
+
+// Checks a signed JWT sent as a Bearer token, and sets its user and scopes for the rest of the request
+// The token should be signed with an accepted method, not be expired and have the issuer and audience which are set
+// The user is the one whose username is in the username claim, and the scopes are the read, write and admin scopes in the scope claim
+// If the token is wrong, expired or its user does not exist, its rejected with 401
+func authenticateJWT(c *gin.Context, db *sql.DB, bearerToken string) {
+
+	// Only the signing methods which have a key set are accepted
+	signingMethods := []string{}
+	if jwtSecret != "" {
+		signingMethods = append(signingMethods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(jwtPublicKeys) > 0 {
+		signingMethods = append(signingMethods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(signingMethods) == 0 {
+		rejectInvalidToken(c, "Bearer tokens are not accepted, please send an API key or sign in with your username and password")
+		return
+	}
+
+	// Parse the token, checking its signature, expiry, issuer and audience, a little leeway is allowed for clocks which are not in sync
+	parserOptions := []jwt.ParserOption{jwt.WithValidMethods(signingMethods), jwt.WithExpirationRequired(), jwt.WithLeeway(30 * time.Second)}
+	if jwtIssuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(jwtIssuer))
+	}
+	if jwtAudience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(jwtAudience))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(bearerToken, claims, getJWTKey, parserOptions...); err != nil {
+		rejectInvalidToken(c, "Incorrect or expired token")
+		return
+	}
+
+	// Get the user by the username in the token
+	username, _ := claims[jwtUsernameClaim].(string)
+	queryToGetUser := `SELECT ID, ISADMIN FROM USERS WHERE USERNAME = $1;`
+	var userID string
+	var isAdmin bool
+	db.QueryRow(queryToGetUser, username).Scan(&userID, &isAdmin)
+	if len(userID) == 0 {
+		rejectInvalidToken(c, "No user for the token")
+		return
+	}
+
+	// The scopes are in the scope claim separated by spaces, or in a scopes claim as a list, other scopes are ignored
+	grantedScopes := []string{}
+	if scopeClaim, isText := claims["scope"].(string); isText {
+		grantedScopes = strings.Fields(scopeClaim)
+	}
+	if scopesClaim, isList := claims["scopes"].([]any); isList {
+		for _, scope := range scopesClaim {
+			if scopeText, isText := scope.(string); isText {
+				grantedScopes = append(grantedScopes, scopeText)
+			}
+		}
+	}
+	scopes := []string{}
+	for _, scope := range grantedScopes {
+		if slices.Contains(apiKeyScopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	// A token for an admin only acts as an admin if it has the admin scope
+	c.Set("userID", userID)
+	c.Set("scopes", scopes)
+	c.Set("isAdmin", isAdmin && hasScope(c, "admin"))
+	c.Next()
+
+}
+
+// Rejects a request with a Bearer token with 401, asking the client for a valid token
+func rejectInvalidToken(c *gin.Context, status string) {
+
+	c.Header("WWW-Authenticate", `Bearer realm="Book Management", error="invalid_token"`)
+	c.AbortWithStatusJSON(401, gin.H{"status": status})
+
+}
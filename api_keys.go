@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every API key starts with this prefix, so it can be told apart from other Bearer tokens
+const apiKeyPrefix = "bm_"
+
+// Scopes an API key can have, in order, each scope allows everything the scopes before it do
+// read allows the GET endpoints, write allows every other endpoint, admin allows the endpoints which are only for admins
+var apiKeyScopes = []string{"read", "write", "admin"}
+
+// Returns the API key sent with the request, in the X-API-Key header or as a Bearer token, or empty if none is sent
+func requestAPIKey(c *gin.Context) string {
+
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+
+	bearerToken, isBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if isBearer && strings.HasPrefix(bearerToken, apiKeyPrefix) {
+		return bearerToken
+	}
+
+	return ""
+
+}
+
+// Checks an API key against the hashed keys in the DB, and sets its user and scopes for the rest of the request
+// If the key is wrong or revoked, its rejected with 401
+func authenticateAPIKey(c *gin.Context, db *sql.DB, apiKey string) {
+
+	// Get the key by its hash, along with its user
+	queryToGetAPIKey := `SELECT APIKEYS.ID, APIKEYS.USERID, APIKEYS.SCOPES, APIKEYS.DATEREVOKED, USERS.ISADMIN FROM APIKEYS
+	INNER JOIN USERS ON USERS.ID = APIKEYS.USERID WHERE APIKEYS.KEYHASH = $1;`
+	var apiKeyID, userID, scopes string
+	var dateRevoked int64
+	var isAdmin bool
+	db.QueryRow(queryToGetAPIKey, hashAPIKey(apiKey)).Scan(&apiKeyID, &userID, &scopes, &dateRevoked, &isAdmin)
+	if len(apiKeyID) == 0 {
+		rejectUnauthenticated(c, "Incorrect API key")
+		return
+	}
+	if dateRevoked > 0 {
+		rejectUnauthenticated(c, "API key has been revoked")
+		return
+	}
+
+	// Record when the key was last used
+	queryToRecordUse := `UPDATE APIKEYS SET DATELASTUSED = $1 WHERE ID = $2;`
+	db.Exec(queryToRecordUse, time.Now().Unix(), apiKeyID)
+
+	// An admin's key only acts as an admin if it has the admin scope
+	c.Set("userID", userID)
+	c.Set("scopes", strings.Split(scopes, ","))
+	c.Set("isAdmin", isAdmin && hasScope(c, "admin"))
+	c.Set("apiKeyID", apiKeyID)
+	c.Next()
+
+}
+
+// Allows GET requests with the read scope and every other request with the write scope, else its rejected with 403
+func requireScopeForMethod(c *gin.Context) {
+
+	scope := "write"
+	if c.Request.Method == "GET" || c.Request.Method == "HEAD" {
+		scope = "read"
+	}
+
+	if !hasScope(c, scope) {
+		c.AbortWithStatusJSON(403, gin.H{"status": "This API key or token does not have the " + scope + " scope"})
+		return
+	}
+
+	c.Next()
+
+}
+
+// Checks if the request has a scope, either the scope itself or a scope after it in apiKeyScopes
+func hasScope(c *gin.Context, scope string) bool {
+
+	requiredLevel := slices.Index(apiKeyScopes, scope)
+	for _, grantedScope := range c.GetStringSlice("scopes") {
+		if slices.Index(apiKeyScopes, grantedScope) >= requiredLevel {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// Returns the SHA-256 hash of an API key, keys are long and random, so a fast hash is enough and they can be looked up by it
+func hashAPIKey(apiKey string) string {
+
+	hash := sha256.Sum256([]byte(apiKey))
+
+	return hex.EncodeToString(hash[:])
+
+}
+
+// Defining JSON body for createAPIKey(). It requires 2 JSON key's name and scopes. username is optional, only admins can create keys for other users.
+type CreateAPIKeyParameters struct {
+	Name     string   `json:"name" binding:"required"`
+	Scopes   []string `json:"scopes" binding:"required"`
+	Username string   `json:"username"`
+}
+
+// Creates an API key for the signed in user, or for another user if an admin creates it
+// The key is only returned once, only its hash is saved
+func createAPIKey(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, CreateAPIKeyParameters
+	var createAPIKeyParameters CreateAPIKeyParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&createAPIKeyParameters) != nil || len(createAPIKeyParameters.Scopes) == 0 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Each scope should be supported and the key cannot have a scope which the request does not have, else reject with 400 or 403
+	scopes := []string{}
+	for _, scope := range createAPIKeyParameters.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			c.JSON(400, gin.H{"status": "Incorrect scope, " + scope + ", scopes should be read, write or admin"})
+			return
+		}
+		if !hasScope(c, scope) {
+			c.JSON(403, gin.H{"status": "Cannot create an API key with the " + scope + " scope"})
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Only admins can create keys for other users, the user should exist, else reject with 403 or 404
+	userID := signedInUserID(c)
+	if createAPIKeyParameters.Username != "" && !strings.EqualFold(createAPIKeyParameters.Username, currentUsername(db, c)) {
+		if !c.GetBool("isAdmin") {
+			c.JSON(403, gin.H{"status": "Only admins can create API keys for other users"})
+			return
+		}
+		var isAdmin bool
+		userID = ""
+		db.QueryRow(`SELECT ID, ISADMIN FROM USERS WHERE USERNAME = $1;`, createAPIKeyParameters.Username).Scan(&userID, &isAdmin)
+		if len(userID) == 0 {
+			c.JSON(404, gin.H{"status": "No user by username, " + createAPIKeyParameters.Username + " exists"})
+			return
+		}
+		if slices.Contains(scopes, "admin") && !isAdmin {
+			c.JSON(403, gin.H{"status": "Only admins can have API keys with the admin scope"})
+			return
+		}
+	}
+
+	// Generate a random key, only its hash and its first characters, to tell it apart in the list of keys, are saved
+	randomBytes := make([]byte, 32)
+	if _, err = rand.Read(randomBytes); err != nil {
+		c.JSON(500, gin.H{"status": "Could not create the API key"})
+		return
+	}
+	apiKey := apiKeyPrefix + hex.EncodeToString(randomBytes)
+	generatedID := uniqueIDGenerator()
+	queryToAddAPIKey := `INSERT INTO APIKEYS (ID, USERID, NAME, KEYHASH, KEYPREFIX, SCOPES, DATECREATED) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	_, err = db.Exec(queryToAddAPIKey, generatedID, userID, sanitizeString(createAPIKeyParameters.Name), hashAPIKey(apiKey), apiKey[:len(apiKeyPrefix)+8],
+		strings.Join(scopes, ","), time.Now().Unix())
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "API Key Created, it is only shown once", "keyID": generatedID, "key": apiKey, "name": sanitizeString(createAPIKeyParameters.Name),
+		"scopes": scopes})
+
+}
+
+// Returns the username of the signed in user
+func currentUsername(db *sql.DB, c *gin.Context) string {
+
+	var username string
+	db.QueryRow(`SELECT USERNAME FROM USERS WHERE ID = $1;`, signedInUserID(c)).Scan(&username)
+
+	return username
+
+}
+
+// Defining Query Parameters for getAPIKeys(). all is optional, admins can set it to true to get every user's keys.
+type GetAPIKeysParameters struct {
+	All bool `form:"all"`
+}
+
+// Returns the signed in user's API keys, or every user's keys for admins, the keys themselves are never returned
+func getAPIKeys(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetAPIKeysParameters
+	var getAPIKeysParameters GetAPIKeysParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&getAPIKeysParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+	if getAPIKeysParameters.All && !c.GetBool("isAdmin") {
+		c.JSON(403, gin.H{"status": "Only admins can get every user's API keys"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetAPIKeys := `SELECT APIKEYS.ID, APIKEYS.NAME, USERS.USERNAME, APIKEYS.KEYPREFIX, APIKEYS.SCOPES, APIKEYS.DATECREATED, APIKEYS.DATELASTUSED, APIKEYS.DATEREVOKED
+	FROM APIKEYS INNER JOIN USERS ON USERS.ID = APIKEYS.USERID WHERE $1 OR APIKEYS.USERID = $2 ORDER BY APIKEYS.DATECREATED;`
+	result, err := db.Query(queryToGetAPIKeys, getAPIKeysParameters.All, signedInUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetAPIKeyDetails struct {
+		ID           string   `json:"id"`
+		Name         string   `json:"name"`
+		Username     string   `json:"username"`
+		KeyPrefix    string   `json:"keyPrefix"`
+		Scopes       []string `json:"scopes"`
+		DateCreated  string   `json:"dateCreated"`
+		DateLastUsed string   `json:"dateLastUsed"`
+		DateRevoked  string   `json:"dateRevoked"`
+		Revoked      bool     `json:"revoked"`
+	}
+
+	// Creating a slice from the struct
+	apiKeys := []GetAPIKeyDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and variables to hold the scopes and the times in Epoch time
+		apiKey := GetAPIKeyDetails{}
+		var scopes string
+		var dateCreated, dateLastUsed, dateRevoked int64
+
+		// Scan the results into the struct
+		result.Scan(&apiKey.ID, &apiKey.Name, &apiKey.Username, &apiKey.KeyPrefix, &scopes, &dateCreated, &dateLastUsed, &dateRevoked)
+
+		// The times are returned in RFC 3339 format, or empty if the key was never used or is not revoked
+		apiKey.Scopes = strings.Split(scopes, ",")
+		apiKey.DateCreated = formatAPIKeyTime(dateCreated)
+		apiKey.DateLastUsed = formatAPIKeyTime(dateLastUsed)
+		apiKey.DateRevoked = formatAPIKeyTime(dateRevoked)
+		apiKey.Revoked = dateRevoked > 0
+
+		// Append to the slice
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"apiKeys": apiKeys})
+
+}
+
+// Formats a time in Epoch time in RFC 3339 format, 0 is returned as empty
+func formatAPIKeyTime(epochTime int64) string {
+
+	if epochTime == 0 {
+		return ""
+	}
+
+	return time.Unix(epochTime, 0).UTC().Format(time.RFC3339)
+
+}
+
+// Defining JSON body for revokeAPIKey(). It requires 1 JSON key keyID.
+type RevokeAPIKeyParameters struct {
+	KeyID string `json:"keyID" binding:"required"`
+}
+
+// Revokes one of the signed in user's API keys, admins can revoke any user's keys
+// Revoked keys are kept, so they are still listed with when they were last used
+func revokeAPIKey(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RevokeAPIKeyParameters
+	var revokeAPIKeyParameters RevokeAPIKeyParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&revokeAPIKeyParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the key exists and belongs to the user, admins can revoke any key, if not, reject with 404
+	queryToCheckExistingAPIKey := `SELECT ID, DATEREVOKED FROM APIKEYS WHERE ID = $1 AND ($2 OR USERID = $3);`
+	var checkResult string
+	var dateRevoked int64
+	db.QueryRow(queryToCheckExistingAPIKey, revokeAPIKeyParameters.KeyID, c.GetBool("isAdmin"), signedInUserID(c)).Scan(&checkResult, &dateRevoked)
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No API key with ID, " + revokeAPIKeyParameters.KeyID + " exists"})
+		return
+	}
+
+	// If the key is already revoked, reject with 403
+	if dateRevoked > 0 {
+		c.JSON(403, gin.H{"status": "API key, " + revokeAPIKeyParameters.KeyID + " is already revoked"})
+		return
+	}
+
+	queryToRevokeAPIKey := `UPDATE APIKEYS SET DATEREVOKED = $1 WHERE ID = $2;`
+	if _, err = db.Exec(queryToRevokeAPIKey, time.Now().Unix(), checkResult); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "API Key, " + checkResult + " revoked."})
+
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles a user can have in a library, in order, each role can do everything the roles before it can
+// A viewer can read the library, an editor can also change its Books and an owner can also manage its members and share tokens
+// Every user is the owner of their own library
+var libraryRoles = []string{"viewer", "editor", "owner"}
+
+// Every share token starts with this prefix, so it can be told apart from API keys
+const shareTokenPrefix = "bms_"
+
+// Picks the library the request works on, the signed in user's own library, or a library shared with them by its owner's username
+// The library is picked with the X-Library header or the library Query Parameter
+// In a shared library, viewers can only use the GET endpoints, and nobody acts as an admin
+// If the library is not shared with the user, its rejected with 404
+func selectLibrary(c *gin.Context) {
+
+	c.Set("signedInUserID", currentUserID(c))
+	c.Set("libraryRole", "owner")
+
+	// Without a library, or with the user's own library, nothing changes
+	libraryUsername := c.GetHeader("X-Library")
+	if libraryUsername == "" {
+		libraryUsername = c.Query("library")
+	}
+	if libraryUsername == "" {
+		c.Next()
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the library's owner and the user's role in it, the owner's role in their own library is owner
+	queryToGetLibraryRole := `SELECT USERS.ID, CASE WHEN USERS.ID = $2 THEN 'owner' ELSE COALESCE(LIBRARYMEMBERS.ROLE, '') END FROM USERS
+	LEFT JOIN LIBRARYMEMBERS ON LIBRARYMEMBERS.LIBRARYID = USERS.ID AND LIBRARYMEMBERS.USERID = $2 WHERE USERS.USERNAME = $1;`
+	var libraryID, libraryRole string
+	db.QueryRow(queryToGetLibraryRole, libraryUsername, currentUserID(c)).Scan(&libraryID, &libraryRole)
+	if libraryRole == "" {
+		c.AbortWithStatusJSON(404, gin.H{"status": "No library of " + libraryUsername + " is shared with you"})
+		return
+	}
+	if libraryID == currentUserID(c) {
+		c.Next()
+		return
+	}
+
+	// Viewers can only read
+	if libraryRole == "viewer" && c.Request.Method != "GET" && c.Request.Method != "HEAD" {
+		c.AbortWithStatusJSON(403, gin.H{"status": "Viewers of a library cannot change it"})
+		return
+	}
+
+	c.Set("userID", libraryID)
+	c.Set("libraryRole", libraryRole)
+	c.Set("isAdmin", false)
+	c.Next()
+
+}
+
+// Allows the request only for the owners of the library it works on, else its rejected with 403
+// Used for the endpoints which manage a library's members and share tokens
+func requireLibraryOwner(c *gin.Context) {
+
+	if c.GetString("libraryRole") != "owner" {
+		c.AbortWithStatusJSON(403, gin.H{"status": "Only the owners of a library can do this"})
+		return
+	}
+
+	c.Next()
+
+}
+
+// Checks the share token sent with the shareToken Query Parameter, and sets its library for the rest of the request, with only the read scope
+// If the token is missing, wrong, revoked or expired, its rejected with 401
+func authenticateShareToken(c *gin.Context) {
+
+	shareToken := c.Query("shareToken")
+	if shareToken == "" {
+		c.AbortWithStatusJSON(401, gin.H{"status": "Please provide a share token"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the token by its hash, share tokens are hashed the same way as API keys
+	queryToGetShareToken := `SELECT LIBRARYID, DATEEXPIRES, DATEREVOKED FROM SHARETOKENS WHERE TOKENHASH = $1;`
+	var libraryID string
+	var dateExpires, dateRevoked int64
+	db.QueryRow(queryToGetShareToken, hashAPIKey(shareToken)).Scan(&libraryID, &dateExpires, &dateRevoked)
+	if len(libraryID) == 0 || dateRevoked > 0 || (dateExpires > 0 && dateExpires < time.Now().Unix()) {
+		c.AbortWithStatusJSON(401, gin.H{"status": "Incorrect, revoked or expired share token"})
+		return
+	}
+
+	c.Set("userID", libraryID)
+	c.Set("scopes", []string{"read"})
+	c.Set("libraryRole", "shared")
+	c.Next()
+
+}
+
+// Defining JSON body for addLibraryMember(). It requires 2 JSON key's username and role.
+type AddLibraryMemberParameters struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// Shares the library with another user as an owner, editor or viewer, if they are already a member, their role is changed
+func addLibraryMember(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, AddLibraryMemberParameters
+	var addLibraryMemberParameters AddLibraryMemberParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&addLibraryMemberParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+	if !slices.Contains(libraryRoles, addLibraryMemberParameters.Role) {
+		c.JSON(400, gin.H{"status": "Incorrect role, role should be owner, editor or viewer"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the user exists, if not, reject with 404, the library's own user cannot be added
+	queryToGetUser := `SELECT ID FROM USERS WHERE USERNAME = $1;`
+	var memberID string
+	db.QueryRow(queryToGetUser, addLibraryMemberParameters.Username).Scan(&memberID)
+	if len(memberID) == 0 {
+		c.JSON(404, gin.H{"status": "No user by username, " + addLibraryMemberParameters.Username + " exists"})
+		return
+	}
+	if memberID == currentUserID(c) {
+		c.JSON(403, gin.H{"status": "The library's own user is always its owner"})
+		return
+	}
+
+	// Add the member, or change their role
+	queryToAddMember := `INSERT INTO LIBRARYMEMBERS (LIBRARYID, USERID, ROLE, DATEADDED) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (LIBRARYID, USERID) DO UPDATE SET ROLE = EXCLUDED.ROLE;`
+	if _, err = db.Exec(queryToAddMember, currentUserID(c), memberID, addLibraryMemberParameters.Role, todaysDateInEpoch()); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Library shared with " + addLibraryMemberParameters.Username + " as " + addLibraryMemberParameters.Role + "."})
+
+}
+
+// Defining JSON body for removeLibraryMember(). It requires 1 Query Parameter username.
+type RemoveLibraryMemberParameters struct {
+	Username string `form:"username" binding:"required"`
+}
+
+// Stops sharing the library with a user
+func removeLibraryMember(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RemoveLibraryMemberParameters
+	var removeLibraryMemberParameters RemoveLibraryMemberParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&removeLibraryMemberParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Remove the member, if nothing was removed, the library is not shared with them, reject with 404
+	queryToRemoveMember := `DELETE FROM LIBRARYMEMBERS WHERE LIBRARYID = $1 AND USERID = (SELECT ID FROM USERS WHERE USERNAME = $2);`
+	removed, err := db.Exec(queryToRemoveMember, currentUserID(c), removeLibraryMemberParameters.Username)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	if rowsRemoved, _ := removed.RowsAffected(); rowsRemoved == 0 {
+		c.JSON(404, gin.H{"status": "Library is not shared with " + removeLibraryMemberParameters.Username})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Library no longer shared with " + removeLibraryMemberParameters.Username + "."})
+
+}
+
+// Returns the members of the library and their roles
+func getLibraryMembers(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetMembers := `SELECT USERS.USERNAME, LIBRARYMEMBERS.ROLE, LIBRARYMEMBERS.DATEADDED FROM LIBRARYMEMBERS
+	INNER JOIN USERS ON USERS.ID = LIBRARYMEMBERS.USERID WHERE LIBRARYMEMBERS.LIBRARYID = $1 ORDER BY USERS.USERNAME;`
+	result, err := db.Query(queryToGetMembers, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type LibraryMember struct {
+		Username  string `json:"username"`
+		Role      string `json:"role"`
+		DateAdded string `json:"dateAdded"`
+	}
+
+	// Creating a slice from the struct
+	libraryMembers := []LibraryMember{}
+
+	// Iterating over the results
+	for result.Next() {
+		libraryMember := LibraryMember{}
+		var dateAdded int
+		result.Scan(&libraryMember.Username, &libraryMember.Role, &dateAdded)
+		libraryMember.DateAdded = convertEpochToDate(dateAdded)
+		libraryMembers = append(libraryMembers, libraryMember)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"members": libraryMembers})
+
+}
+
+// Returns the libraries shared with the signed in user and their role in each, their own library is first
+func getSharedLibraries(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetLibraries := `SELECT USERNAME, 'owner', 0 FROM USERS WHERE ID = $1
+	UNION ALL
+	SELECT USERS.USERNAME, LIBRARYMEMBERS.ROLE, 1 FROM LIBRARYMEMBERS INNER JOIN USERS ON USERS.ID = LIBRARYMEMBERS.LIBRARYID
+	WHERE LIBRARYMEMBERS.USERID = $1 ORDER BY 3, 1;`
+	result, err := db.Query(queryToGetLibraries, signedInUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result, the library is named by its owner's username
+	type SharedLibrary struct {
+		Library string `json:"library"`
+		Role    string `json:"role"`
+	}
+
+	// Creating a slice from the struct
+	sharedLibraries := []SharedLibrary{}
+
+	// Iterating over the results
+	for result.Next() {
+		sharedLibrary := SharedLibrary{}
+		var isShared int
+		result.Scan(&sharedLibrary.Library, &sharedLibrary.Role, &isShared)
+		sharedLibraries = append(sharedLibraries, sharedLibrary)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"libraries": sharedLibraries})
+
+}
+
+// Defining JSON body for createShareToken(). It requires 1 JSON key name, expiresInDays is optional, without it the token does not expire.
+type CreateShareTokenParameters struct {
+	Name          string `json:"name" binding:"required"`
+	ExpiresInDays int    `json:"expiresInDays"`
+}
+
+// Creates a share token, which gives read only access to the library's Books, finished Books and stats without signing in
+// The token is only returned once, with the links it works with, only its hash is saved
+func createShareToken(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, CreateShareTokenParameters
+	var createShareTokenParameters CreateShareTokenParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&createShareTokenParameters) != nil || createShareTokenParameters.ExpiresInDays < 0 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Generate a random token, and when it expires, if it does
+	randomBytes := make([]byte, 32)
+	if _, err = rand.Read(randomBytes); err != nil {
+		c.JSON(500, gin.H{"status": "Could not create the share token"})
+		return
+	}
+	shareToken := shareTokenPrefix + hex.EncodeToString(randomBytes)
+	dateCreated := time.Now()
+	dateExpires := int64(0)
+	if createShareTokenParameters.ExpiresInDays > 0 {
+		dateExpires = dateCreated.AddDate(0, 0, createShareTokenParameters.ExpiresInDays).Unix()
+	}
+
+	generatedID := uniqueIDGenerator()
+	queryToAddShareToken := `INSERT INTO SHARETOKENS (ID, LIBRARYID, NAME, TOKENHASH, DATECREATED, DATEEXPIRES) VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err = db.Exec(queryToAddShareToken, generatedID, currentUserID(c), sanitizeString(createShareTokenParameters.Name), hashAPIKey(shareToken),
+		dateCreated.Unix(), dateExpires)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Share Token Created, it is only shown once", "tokenID": generatedID, "token": shareToken,
+		"dateExpires": formatAPIKeyTime(dateExpires), "links": []string{"/shared/getAllBooks?shareToken=" + shareToken,
+			"/shared/getAllFinishedBooks?shareToken=" + shareToken, "/shared/getGenreStats?shareToken=" + shareToken,
+			"/shared/getYearInReview?shareToken=" + shareToken + "&year=" + dateCreated.Format("2006")}})
+
+}
+
+// Returns the library's share tokens, the tokens themselves are never returned
+func getShareTokens(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetShareTokens := `SELECT ID, NAME, DATECREATED, DATEEXPIRES, DATEREVOKED FROM SHARETOKENS WHERE LIBRARYID = $1 ORDER BY DATECREATED;`
+	result, err := db.Query(queryToGetShareTokens, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type ShareTokenDetails struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DateCreated string `json:"dateCreated"`
+		DateExpires string `json:"dateExpires"`
+		DateRevoked string `json:"dateRevoked"`
+		Active      bool   `json:"active"`
+	}
+
+	// Creating a slice from the struct
+	shareTokens := []ShareTokenDetails{}
+
+	// Iterating over the results, the times are returned the same way as the API keys' times
+	for result.Next() {
+		shareToken := ShareTokenDetails{}
+		var dateCreated, dateExpires, dateRevoked int64
+		result.Scan(&shareToken.ID, &shareToken.Name, &dateCreated, &dateExpires, &dateRevoked)
+		shareToken.DateCreated = formatAPIKeyTime(dateCreated)
+		shareToken.DateExpires = formatAPIKeyTime(dateExpires)
+		shareToken.DateRevoked = formatAPIKeyTime(dateRevoked)
+		shareToken.Active = dateRevoked == 0 && (dateExpires == 0 || dateExpires >= time.Now().Unix())
+		shareTokens = append(shareTokens, shareToken)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"shareTokens": shareTokens})
+
+}
+
+// Defining JSON body for revokeShareToken(). It requires 1 JSON key tokenID.
+type RevokeShareTokenParameters struct {
+	TokenID string `json:"tokenID" binding:"required"`
+}
+
+// Revokes one of the library's share tokens, its links stop working
+func revokeShareToken(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RevokeShareTokenParameters
+	var revokeShareTokenParameters RevokeShareTokenParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&revokeShareTokenParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the token exists in the library, if not, reject with 404, if its already revoked, reject with 403
+	queryToCheckExistingShareToken := `SELECT ID, DATEREVOKED FROM SHARETOKENS WHERE ID = $1 AND LIBRARYID = $2;`
+	var checkResult string
+	var dateRevoked int64
+	db.QueryRow(queryToCheckExistingShareToken, revokeShareTokenParameters.TokenID, currentUserID(c)).Scan(&checkResult, &dateRevoked)
+	if len(checkResult) == 0 {
+		c.JSON(404, gin.H{"status": "No share token with ID, " + revokeShareTokenParameters.TokenID + " exists"})
+		return
+	}
+	if dateRevoked > 0 {
+		c.JSON(403, gin.H{"status": "Share token, " + revokeShareTokenParameters.TokenID + " is already revoked"})
+		return
+	}
+
+	queryToRevokeShareToken := `UPDATE SHARETOKENS SET DATEREVOKED = $1 WHERE ID = $2;`
+	if _, err = db.Exec(queryToRevokeShareToken, time.Now().Unix(), checkResult); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "Share Token, " + checkResult + " revoked."})
+
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Content types of the OPDS navigation and acquisition feeds
+const opdsNavigationType = "application/atom+xml;profile=opds-catalog;kind=navigation"
+const opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+
+// An Atom feed, used by the OPDS catalog and the feeds of recently finished and added Books
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *AtomAuthor `xml:"author,omitempty"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+// An entry of an Atom feed
+type AtomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Authors    []AtomAuthor   `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+	Content    *AtomContent   `xml:"content,omitempty"`
+	Links      []AtomLink     `xml:"link"`
+}
+
+// An author of an Atom feed or entry
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// A link of an Atom feed or entry
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// A category of an Atom entry
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+// The content of an Atom entry
+type AtomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Returns the root of the OPDS catalog, a navigation feed linking to all the other feeds
+func getOPDSCatalog(c *gin.Context) {
+
+	updated := time.Now().UTC().Format(time.RFC3339)
+
+	// Defining the feeds of the catalog, each becomes an entry of the navigation feed
+	type OPDSSection struct {
+		ID      string
+		Title   string
+		Content string
+		Href    string
+		Type    string
+	}
+	opdsSections := []OPDSSection{
+		{"all", "All Books", "All the available books", "/opds/books?shelf=all", opdsAcquisitionType},
+		{"unread", "Unread Books", "All the unread books", "/opds/books?shelf=unread", opdsAcquisitionType},
+		{"reading", "Reading Books", "All the books being read", "/opds/books?shelf=reading", opdsAcquisitionType},
+		{"finished", "Finished Books", "All the finished books", "/opds/books?shelf=finished", opdsAcquisitionType},
+		{"authors", "By Author", "All the books, by their author", "/opds/authors", opdsNavigationType},
+	}
+
+	opdsFeed := AtomFeed{ID: "urn:bookmanagement:opds", Title: "Book Management", Updated: updated, Author: &AtomAuthor{Name: "Book Management"},
+		Links: opdsFeedLinks("/opds", opdsNavigationType)}
+	for _, opdsSection := range opdsSections {
+		opdsFeed.Entries = append(opdsFeed.Entries, AtomEntry{ID: "urn:bookmanagement:opds:" + opdsSection.ID, Title: opdsSection.Title, Updated: updated,
+			Content: &AtomContent{Type: "text", Text: opdsSection.Content}, Links: []AtomLink{{Rel: "subsection", Href: opdsSection.Href, Type: opdsSection.Type}}})
+	}
+
+	writeAtomFeed(c, opdsFeed, opdsNavigationType)
+
+}
+
+// Returns a navigation feed of all the authors, each linking to a feed of their Books
+func getOPDSAuthors(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB for all the authors in the user's library and the number of Books by each of them
+	queryToGetAuthors := `SELECT AUTHOR, COUNT(*) FROM BOOKMANAGEMENT WHERE USERID = $1 GROUP BY AUTHOR ORDER BY AUTHOR;`
+	result, err := db.Query(queryToGetAuthors, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	updated := time.Now().UTC().Format(time.RFC3339)
+	opdsFeed := AtomFeed{ID: "urn:bookmanagement:opds:authors", Title: "By Author", Updated: updated, Links: opdsFeedLinks("/opds/authors", opdsNavigationType)}
+
+	// Iterating over the results, each author becomes an entry linking to their Books
+	for result.Next() {
+		var author string
+		var bookCount int
+		result.Scan(&author, &bookCount)
+		opdsFeed.Entries = append(opdsFeed.Entries, AtomEntry{ID: "urn:bookmanagement:opds:author:" + url.QueryEscape(author), Title: author, Updated: updated,
+			Content: &AtomContent{Type: "text", Text: strconv.Itoa(bookCount) + " books"},
+			Links:   []AtomLink{{Rel: "subsection", Href: "/opds/books?author=" + url.QueryEscape(author), Type: opdsAcquisitionType}}})
+	}
+
+	writeAtomFeed(c, opdsFeed, opdsNavigationType)
+
+}
+
+// Defining Query Parameters for getOPDSBooks(). shelf can be all, the default, unread, reading or finished, author is optional.
+type GetOPDSBooksParameters struct {
+	Shelf  string `form:"shelf"`
+	Author string `form:"author"`
+}
+
+// Returns an acquisition feed of the Books on a shelf, or by an author
+// The Books are picked out with the same conditions as getAllUnreadBooks(), getAllReadingBooks(), getAllFinishedBooks() and getBooksByAuthor()
+func getOPDSBooks(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetOPDSBooksParameters
+	var getOPDSBooksParameters GetOPDSBooksParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&getOPDSBooksParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Pick the condition and the title of the feed, if the shelf is not supported, reject with 400
+	shelfConditions := map[string]string{"": "1 = 1", "all": "1 = 1", "unread": unreadBooksCondition, "reading": readingBooksCondition, "finished": finishedBooksCondition}
+	shelfTitles := map[string]string{"": "All Books", "all": "All Books", "unread": "Unread Books", "reading": "Reading Books", "finished": "Finished Books"}
+	condition, supportedShelf := shelfConditions[getOPDSBooksParameters.Shelf]
+	if !supportedShelf {
+		c.JSON(400, gin.H{"status": "Incorrect shelf, shelf should be one of all, unread, reading or finished"})
+		return
+	}
+	feedTitle := shelfTitles[getOPDSBooksParameters.Shelf]
+
+	// Only the user's Books are in the feed, Books by an author are picked out the same way as getBooksByAuthor()
+	condition += ` AND USERID = $1`
+	queryParameters := []any{currentUserID(c)}
+	if getOPDSBooksParameters.Author != "" {
+		condition += ` AND AUTHOR = $2`
+		queryParameters = append(queryParameters, getOPDSBooksParameters.Author)
+		feedTitle += " by " + getOPDSBooksParameters.Author
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetBooks := queryToGetBooksWithCovers + ` WHERE ` + condition + ` ORDER BY BOOK;`
+	result, err := db.Query(queryToGetBooks, queryParameters...)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// The feed links back to itself with the same Query Parameters
+	selfHref := "/opds/books"
+	if c.Request.URL.RawQuery != "" {
+		selfHref += "?" + c.Request.URL.RawQuery
+	}
+	updated := time.Now().UTC().Format(time.RFC3339)
+	opdsFeed := AtomFeed{ID: "urn:bookmanagement:opds:books:" + getOPDSBooksParameters.Shelf + ":" + url.QueryEscape(getOPDSBooksParameters.Author), Title: feedTitle, Updated: updated,
+		Links: opdsFeedLinks(selfHref, opdsAcquisitionType)}
+
+	// Iterating over the results, each Book becomes an entry
+	for result.Next() {
+
+		var bookID, book, author, notes, coverBookID string
+		var totalPages, readPages, dateStarted, dateFinished int
+		result.Scan(&bookID, &book, &author, &totalPages, &readPages, &dateStarted, &dateFinished, &notes, &coverBookID)
+
+		// The entry is updated when the Book was last started or finished
+		entryUpdated := updated
+		if dateFinished > 0 {
+			entryUpdated = time.Unix(int64(dateFinished), 0).UTC().Format(time.RFC3339)
+		} else if dateStarted > 0 {
+			entryUpdated = time.Unix(int64(dateStarted), 0).UTC().Format(time.RFC3339)
+		}
+
+		// The shelf of the Book is added as a category, its details and notes as the content
+		shelf := "unread"
+		if dateFinished > 0 {
+			shelf = "finished"
+		} else if dateStarted > 0 {
+			shelf = "reading"
+		}
+		content := strconv.Itoa(totalPages) + " pages, " + strconv.Itoa(readPages) + " read."
+		if dateStarted > 0 {
+			content += " Started on " + convertEpochToDate(dateStarted) + "."
+		}
+		if dateFinished > 0 {
+			content += " Finished on " + convertEpochToDate(dateFinished) + "."
+		}
+		if notes != "" {
+			content += " " + notes
+		}
+
+		// The Book's details are linked, along with its cover and thumbnail, if it has one
+		links := []AtomLink{{Rel: "alternate", Href: "/getBookDetails?bookID=" + bookID, Type: "application/json"}}
+		if len(coverBookID) > 0 {
+			links = append(links, AtomLink{Rel: "http://opds-spec.org/image", Href: coverURL(bookID)},
+				AtomLink{Rel: "http://opds-spec.org/image/thumbnail", Href: coverURL(bookID) + "&size=thumbnail", Type: "image/jpeg"})
+		}
+
+		opdsFeed.Entries = append(opdsFeed.Entries, AtomEntry{ID: bookEntryID(bookID), Title: book, Updated: entryUpdated, Authors: []AtomAuthor{{Name: author}},
+			Categories: []AtomCategory{{Term: shelf}}, Content: &AtomContent{Type: "text", Text: content}, Links: links})
+	}
+
+	writeAtomFeed(c, opdsFeed, opdsAcquisitionType)
+
+}
+
+// Returns the links every OPDS feed has, to itself, to the root of the catalog, and up to the root
+func opdsFeedLinks(selfHref string, feedType string) []AtomLink {
+
+	return []AtomLink{
+		{Rel: "self", Href: selfHref, Type: feedType},
+		{Rel: "start", Href: "/opds", Type: opdsNavigationType},
+		{Rel: "up", Href: "/opds", Type: opdsNavigationType},
+	}
+
+}
+
+// Writes an Atom feed as XML, with the XML declaration, using the given content type
+func writeAtomFeed(c *gin.Context, atomFeed AtomFeed, contentType string) {
+
+	feedXML, err := xml.MarshalIndent(atomFeed, "", "  ")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not create the feed"})
+		return
+	}
+
+	c.Data(200, contentType, append([]byte(xml.Header), feedXML...))
+
+}
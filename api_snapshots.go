@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Folder where the snapshots of the DB are kept
+const snapshotsDirectory = "./snapshots"
+
+// Snapshot file names are made of this prefix and the time they were taken, so sorting them by name sorts them by time
+const snapshotPrefix = "BOOKMANAGEMENT-"
+const snapshotTimeFormat = "20060102-150405.000"
+
+// Number of snapshots to keep, the oldest ones beyond this are deleted, set by SNAPSHOT_RETENTION, defaults to 7, 0 keeps all of them
+var snapshotRetention = getSettingFromEnvironment("SNAPSHOT_RETENTION", 7)
+
+// Hours between the scheduled snapshots, set by SNAPSHOT_INTERVAL_HOURS, defaults to 24, 0 turns off the scheduled snapshots
+var snapshotIntervalHours = getSettingFromEnvironment("SNAPSHOT_INTERVAL_HOURS", 24)
+
+// A snapshot of the DB
+type Snapshot struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+	Size      int64  `json:"size"`
+}
+
+// Takes a snapshot of the DB while the server is running
+func createSnapshot(c *gin.Context) {
+
+	snapshot, err := takeSnapshot()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not take a snapshot, " + err.Error()})
+		return
+	}
+	rotateSnapshots()
+
+	c.JSON(200, gin.H{"status": "Snapshot taken", "snapshot": snapshot})
+
+}
+
+// Returns all the snapshots of the DB, newest first
+func getSnapshots(c *gin.Context) {
+
+	snapshots, err := listSnapshots()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not read the snapshots"})
+		return
+	}
+
+	c.JSON(200, gin.H{"snapshots": snapshots, "retention": snapshotRetention})
+
+}
+
+// Defining JSON body for restoreSnapshot(). It requires 1 JSON key snapshot.
+type RestoreSnapshotParameters struct {
+	Snapshot string `json:"snapshot" binding:"required"`
+}
+
+// Restores the DB to a snapshot, while the server is running
+// A snapshot of the DB as it is now is taken first, so the restore itself can be undone by restoring that snapshot
+// Every table is replaced with the snapshot's rows in a single transaction, tables which are not in the snapshot are left as they are
+func restoreSnapshot(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RestoreSnapshotParameters
+	var restoreSnapshotParameters RestoreSnapshotParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&restoreSnapshotParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Check if the snapshot is one of the listed snapshots, if not, reject with 404
+	// Only listed snapshots can be restored, so the name cannot point to a file outside the snapshots folder
+	snapshots, err := listSnapshots()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not read the snapshots"})
+		return
+	}
+	snapshotFound := false
+	for _, snapshot := range snapshots {
+		snapshotFound = snapshotFound || snapshot.Name == restoreSnapshotParameters.Snapshot
+	}
+	if !snapshotFound {
+		c.JSON(404, gin.H{"status": "No Snapshot by name, " + restoreSnapshotParameters.Snapshot + " exists."})
+		return
+	}
+
+	// Take a snapshot of the DB as it is now, before it is replaced
+	// The snapshots are only rotated after the restore, so the snapshot being restored is not deleted before it is read
+	safetySnapshot, err := takeSnapshot()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not take a snapshot before restoring, " + err.Error()})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// The snapshot is attached to a single connection, so the restore has to run on that connection
+	ctx := context.Background()
+	connection, err := db.Conn(ctx)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer connection.Close()
+
+	// Attach the snapshot, read only, so it can be queried alongside the DB
+	snapshotURI := "file:" + filepath.ToSlash(filepath.Join(snapshotsDirectory, restoreSnapshotParameters.Snapshot)) + "?mode=ro"
+	if _, err = connection.ExecContext(ctx, `ATTACH DATABASE $1 AS SNAPSHOT;`, snapshotURI); err != nil {
+		c.JSON(500, gin.H{"status": "Could not open the snapshot"})
+		return
+	}
+	defer connection.ExecContext(ctx, `DETACH DATABASE SNAPSHOT;`)
+
+	// Get the tables which are in both the DB and the snapshot, AUDITLOG is left as it is, as it is only written by addAuditEntries
+	queryToGetSharedTables := `SELECT NAME FROM main.sqlite_master WHERE TYPE = 'table' AND NAME NOT LIKE 'sqlite_%' AND NAME != 'AUDITLOG'
+	AND NAME IN (SELECT NAME FROM SNAPSHOT.sqlite_master WHERE TYPE = 'table') ORDER BY NAME;`
+	result, err := connection.QueryContext(ctx, queryToGetSharedTables)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	tableNames := []string{}
+	for result.Next() {
+		var tableName string
+		result.Scan(&tableName)
+		tableNames = append(tableNames, tableName)
+	}
+	result.Close()
+
+	// All the tables are replaced in a single transaction, so a failed restore leaves the DB as it was
+	transaction, err := connection.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer transaction.Rollback()
+
+	// Defining a struct to hold the outcome of each table
+	type RestoredTable struct {
+		Table    string `json:"table"`
+		Restored int64  `json:"restored"`
+	}
+	restoredTables := []RestoredTable{}
+
+	for _, tableName := range tableNames {
+
+		// Only the columns which are in both the DB and the snapshot are copied, so a snapshot from before a column was added can still be restored
+		// The table and column names come from the DB, so they are safe to use in the query
+		queryToGetSharedColumns := `SELECT NAME FROM pragma_table_info($1, 'main') WHERE NAME IN (SELECT NAME FROM pragma_table_info($1, 'SNAPSHOT'));`
+		result, err := transaction.Query(queryToGetSharedColumns, tableName)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		columns := []string{}
+		for result.Next() {
+			var column string
+			result.Scan(&column)
+			columns = append(columns, `"`+column+`"`)
+		}
+		result.Close()
+
+		if _, err = transaction.Exec(`DELETE FROM main."` + tableName + `";`); err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		columnList := strings.Join(columns, ", ")
+		restored, err := transaction.Exec(`INSERT INTO main."` + tableName + `" (` + columnList + `) SELECT ` + columnList + ` FROM SNAPSHOT."` + tableName + `";`)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not restore table " + tableName + ", " + err.Error()})
+			return
+		}
+		rowsRestored, _ := restored.RowsAffected()
+		restoredTables = append(restoredTables, RestoredTable{Table: tableName, Restored: rowsRestored})
+	}
+
+	if err = transaction.Commit(); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	rotateSnapshots()
+
+	c.JSON(200, gin.H{"status": "Snapshot " + restoreSnapshotParameters.Snapshot + " restored.", "tables": restoredTables, "snapshotBeforeRestore": safetySnapshot})
+
+}
+
+// Takes a consistent snapshot of the DB with VACUUM INTO, which can run while other connections are using the DB
+func takeSnapshot() (Snapshot, error) {
+
+	if err := os.MkdirAll(snapshotsDirectory, 0755); err != nil {
+		return Snapshot{}, err
+	}
+
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer db.Close()
+
+	snapshotTime := time.Now().UTC()
+	snapshotName := snapshotPrefix + snapshotTime.Format(snapshotTimeFormat) + ".db"
+	if _, err = db.Exec(`VACUUM INTO $1;`, filepath.Join(snapshotsDirectory, snapshotName)); err != nil {
+		return Snapshot{}, err
+	}
+
+	fileInfo, err := os.Stat(filepath.Join(snapshotsDirectory, snapshotName))
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{Name: snapshotName, CreatedAt: snapshotTime.Format(time.RFC3339), Size: fileInfo.Size()}, nil
+
+}
+
+// Returns all the snapshots in the snapshots folder, newest first
+// Files which do not look like a snapshot are left out
+func listSnapshots() ([]Snapshot, error) {
+
+	snapshots := []Snapshot{}
+
+	entries, err := os.ReadDir(snapshotsDirectory)
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		snapshotTime, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(strings.TrimPrefix(entry.Name(), snapshotPrefix), ".db"))
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".db") || err != nil {
+			continue
+		}
+		fileInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Name: entry.Name(), CreatedAt: snapshotTime.Format(time.RFC3339), Size: fileInfo.Size()})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name > snapshots[j].Name })
+
+	return snapshots, nil
+
+}
+
+// Deletes the oldest snapshots, keeping the number of snapshots set by the retention
+func rotateSnapshots() {
+
+	snapshots, err := listSnapshots()
+	if err != nil || snapshotRetention < 1 || len(snapshots) <= snapshotRetention {
+		return
+	}
+
+	for _, snapshot := range snapshots[snapshotRetention:] {
+		os.Remove(filepath.Join(snapshotsDirectory, snapshot.Name))
+	}
+
+}
+
+// Takes a snapshot of the DB every snapshotIntervalHours, for as long as the server is running
+// Does nothing if the interval is 0 or less
+func scheduleSnapshots() {
+
+	if snapshotIntervalHours < 1 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(snapshotIntervalHours) * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := takeSnapshot(); err != nil {
+			log.Println("Could not take the scheduled snapshot, ", err)
+			continue
+		}
+		rotateSnapshots()
+	}
+
+}
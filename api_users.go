@@ -0,0 +1,353 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Shortest password which can be used for an account
+const minPasswordLength = 8
+
+// Checks the credentials sent with the request, and sets the user and their scopes for the rest of the request
+// An API key is sent in the X-API-Key header or as a Bearer token, a signed JWT is sent as a Bearer token,
+// else the username and password are sent with HTTP Basic authentication
+// If they are missing or wrong, its rejected with 401, so clients like browsers and e-reader apps ask for them
+func authenticateUser(c *gin.Context) {
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// If an API key is sent, the request is authenticated with it instead
+	if apiKey := requestAPIKey(c); apiKey != "" {
+		authenticateAPIKey(c, db, apiKey)
+		return
+	}
+
+	// Any other Bearer token is a JWT
+	if bearerToken, isBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); isBearer {
+		authenticateJWT(c, db, bearerToken)
+		return
+	}
+
+	// Get the username and password from the Authorization header
+	username, password, supplied := c.Request.BasicAuth()
+	if !supplied {
+		rejectUnauthenticated(c, "Please sign in with your username and password, or send an API key or a token")
+		return
+	}
+
+	// Get the user by their username and check the password against its hash
+	queryToGetUser := `SELECT ID, PASSWORDHASH, ISADMIN FROM USERS WHERE USERNAME = $1;`
+	var userID, passwordHash string
+	var isAdmin bool
+	db.QueryRow(queryToGetUser, username).Scan(&userID, &passwordHash, &isAdmin)
+	if len(userID) == 0 || bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		rejectUnauthenticated(c, "Incorrect username or password")
+		return
+	}
+
+	// A user signed in with their password has every scope, the admin scope only if they are an admin
+	scopes := []string{"read", "write"}
+	if isAdmin {
+		scopes = append(scopes, "admin")
+	}
+
+	c.Set("userID", userID)
+	c.Set("isAdmin", isAdmin)
+	c.Set("scopes", scopes)
+	c.Next()
+
+}
+
+// Allows the request only for admins, signed in with their password or with an API key with the admin scope, else its rejected with 403
+// Used for the endpoints which work on the whole DB, like backups and snapshots
+func requireAdmin(c *gin.Context) {
+
+	if !c.GetBool("isAdmin") || !hasScope(c, "admin") {
+		c.AbortWithStatusJSON(403, gin.H{"status": "Only admins can do this"})
+		return
+	}
+
+	c.Next()
+
+}
+
+// Rejects a request with 401, asking the client to sign in with HTTP Basic authentication
+func rejectUnauthenticated(c *gin.Context, status string) {
+
+	c.Header("WWW-Authenticate", `Basic realm="Book Management", charset="UTF-8"`)
+	c.AbortWithStatusJSON(401, gin.H{"status": status})
+
+}
+
+// Returns the ID of the user whose library the request works on, every Book belongs to a user
+// This is the signed in user, unless they picked a library shared with them
+func currentUserID(c *gin.Context) string {
+
+	return c.GetString("userID")
+
+}
+
+// Returns the ID of the signed in user, even if they are working on a library shared with them
+func signedInUserID(c *gin.Context) string {
+
+	return c.GetString("signedInUserID")
+
+}
+
+// Defining JSON body for registerUser(). It requires 2 JSON key's username, password.
+type RegisterUserParameters struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Registers a user account, each user has their own library of Books
+// The first account is an admin and takes over the Books added before there were accounts
+func registerUser(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, RegisterUserParameters
+	var registerUserParameters RegisterUserParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&registerUserParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Usernames cannot have spaces or a colon, as the colon separates the username and password in HTTP Basic authentication
+	username := sanitizeString(registerUserParameters.Username)
+	if len(username) == 0 || strings.ContainsAny(username, " :") {
+		c.JSON(400, gin.H{"status": "Username cannot be empty or have spaces or a colon"})
+		return
+	}
+	if len(registerUserParameters.Password) < minPasswordLength {
+		c.JSON(400, gin.H{"status": "Password should be at least 8 characters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Check if the username is already taken, if yes, reject with 403
+	queryToCheckExistingUser := `SELECT ID FROM USERS WHERE USERNAME = $1;`
+	var checkResult string
+	db.QueryRow(queryToCheckExistingUser, username).Scan(&checkResult)
+	if len(checkResult) > 0 {
+		c.JSON(403, gin.H{"status": "Username, " + username + " is already taken"})
+		return
+	}
+
+	// Hash the password, only the hash is saved
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(registerUserParameters.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not save the password"})
+		return
+	}
+
+	// Add the user, and if its the first user, make them an admin and give them the Books and Genres which do not belong to anyone, in a single transaction
+	transaction, err := db.Begin()
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer transaction.Rollback()
+
+	var userCount int
+	transaction.QueryRow(`SELECT COUNT(*) FROM USERS;`).Scan(&userCount)
+	isAdmin := userCount == 0
+
+	generatedID := uniqueIDGenerator()
+	queryToAddUser := `INSERT INTO USERS (ID, USERNAME, PASSWORDHASH, ISADMIN, DATECREATED) VALUES ($1, $2, $3, $4, $5);`
+	_, err = transaction.Exec(queryToAddUser, generatedID, username, string(passwordHash), isAdmin, time.Now().Unix())
+	if err == nil && isAdmin {
+		queryToTakeOverBooks := `UPDATE BOOKMANAGEMENT SET USERID = $1 WHERE USERID = '';`
+		_, err = transaction.Exec(queryToTakeOverBooks, generatedID)
+	}
+	if err == nil && isAdmin {
+		queryToTakeOverGenres := `UPDATE GENRES SET USERID = $1 WHERE USERID = '';`
+		_, err = transaction.Exec(queryToTakeOverGenres, generatedID)
+	}
+	if err == nil {
+		err = transaction.Commit()
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "User Registered", "userID": generatedID, "username": username, "isAdmin": isAdmin})
+
+}
+
+// Returns the signed in user's details
+func getCurrentUser(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the user and the number of Books in their library
+	queryToGetUser := `SELECT USERNAME, ISADMIN, DATECREATED, (SELECT COUNT(*) FROM BOOKMANAGEMENT WHERE USERID = USERS.ID) FROM USERS WHERE ID = $1;`
+	var username string
+	var isAdmin bool
+	var dateCreated int64
+	var bookCount int
+	if db.QueryRow(queryToGetUser, signedInUserID(c)).Scan(&username, &isAdmin, &dateCreated, &bookCount) != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// The library the request works on and the user's role in it are returned as well
+	c.JSON(200, gin.H{"userID": signedInUserID(c), "username": username, "isAdmin": isAdmin, "dateCreated": convertEpochToDate(int(dateCreated)), "books": bookCount,
+		"libraryID": currentUserID(c), "libraryRole": c.GetString("libraryRole")})
+
+}
+
+// Returns the catalog of all the Books in every user's library, each Book once, by its name and author
+// Each Book has the ID of one of its copies, catalogBookID, which can be used with trackABook() to add it to the signed in user's library
+func getCatalog(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result
+	queryToGetCatalog := `SELECT MIN(ID), BOOK, AUTHOR, MAX(TOTALPAGES), COUNT(DISTINCT USERID), MAX(USERID = $1) FROM BOOKMANAGEMENT
+	GROUP BY BOOK, AUTHOR ORDER BY BOOK, AUTHOR;`
+	result, err := db.Query(queryToGetCatalog, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type CatalogBook struct {
+		CatalogBookID string `json:"catalogBookID"`
+		Book          string `json:"book"`
+		Author        string `json:"author"`
+		TotalPages    int    `json:"totalPages"`
+		Readers       int    `json:"readers"`
+		InMyLibrary   bool   `json:"inMyLibrary"`
+	}
+
+	// Creating a slice from the struct
+	catalogBooks := []CatalogBook{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct
+		catalogBook := CatalogBook{}
+
+		// Scan the results into the struct
+		result.Scan(&catalogBook.CatalogBookID, &catalogBook.Book, &catalogBook.Author, &catalogBook.TotalPages, &catalogBook.Readers, &catalogBook.InMyLibrary)
+
+		// Append to the slice
+		catalogBooks = append(catalogBooks, catalogBook)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"catalog": catalogBooks})
+
+}
+
+// Defining JSON body for trackABook(). It requires 1 JSON key catalogBookID.
+type TrackABookParameters struct {
+	CatalogBookID string `json:"catalogBookID" binding:"required"`
+}
+
+// Adds a Book from the catalog to the signed in user's library, so they can track it with their own progress and notes
+func trackABook(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, TrackABookParameters
+	var trackABookParameters TrackABookParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.BindJSON(&trackABookParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the Book from the catalog, the catalog has every user's Books, if its not there, reject with 404
+	queryToGetCatalogBook := `SELECT BOOK, AUTHOR, TOTALPAGES FROM BOOKMANAGEMENT WHERE ID = $1;`
+	var book, author string
+	var totalPages int
+	if db.QueryRow(queryToGetCatalogBook, trackABookParameters.CatalogBookID).Scan(&book, &author, &totalPages) != nil {
+		c.JSON(404, gin.H{"status": "No Book with ID, " + trackABookParameters.CatalogBookID + " exists in the catalog"})
+		return
+	}
+
+	// Check if the Book is already in the user's library, if yes, reject with 403, same as addABook()
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE BOOK=$1 AND AUTHOR=$2 AND USERID=$3;`
+	var checkResult string
+	db.QueryRow(queryToCheckExistingBook, book, author, currentUserID(c)).Scan(&checkResult)
+	if len(checkResult) > 0 {
+		c.JSON(403, gin.H{"status": "Book, " + book + " by " + author + " is already in your library", "bookID": checkResult})
+		return
+	}
+
+	// Add the Book to the user's library, unread and without notes
+	generatedID := uniqueIDGenerator()
+	queryToAddABook := `INSERT INTO BOOKMANAGEMENT (ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	if _, err = db.Exec(queryToAddABook, generatedID, book, author, totalPages, 0, 0, 0, "", currentUserID(c)); err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+
+	// Record when the Book was added, for the recently added feed
+	queryToRecordAddition := `INSERT INTO BOOKADDITIONS (BOOKID, DATEADDED) VALUES ($1, $2);`
+	db.Exec(queryToRecordAddition, generatedID, time.Now().Unix())
+
+	c.JSON(200, gin.H{"status": "Book Added", "bookID": generatedID})
+
+}
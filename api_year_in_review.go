@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	htmlTemplate "html/template"
+	"sort"
+	"strconv"
+	"strings"
+	textTemplate "text/template"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Templates of the year in review report, embedded into the binary so the report works from any folder
+//
+//go:embed templates/year_in_review.html templates/year_in_review.md
+var yearInReviewTemplates embed.FS
+
+// Number of authors listed in the top authors of a year
+const topAuthorsCount = 5
+
+// A finished Book, as listed in the year in review report
+type ReviewedBook struct {
+	ID           string `json:"id"`
+	Book         string `json:"book"`
+	Author       string `json:"author"`
+	TotalPages   int    `json:"totalPages"`
+	DateStarted  string `json:"dateStarted"`
+	DateFinished string `json:"dateFinished"`
+	DaysRead     int64  `json:"daysRead"`
+}
+
+// An author, with the number of Books and pages of theirs finished in a year
+type ReviewedAuthor struct {
+	Author string `json:"author"`
+	Books  int    `json:"books"`
+	Pages  int    `json:"pages"`
+}
+
+// The Books and pages finished in a month, BarPercent is the month's pages as a percentage of the busiest month, for the chart
+type ReviewedMonth struct {
+	Month      string `json:"month"`
+	Books      int    `json:"books"`
+	Pages      int    `json:"pages"`
+	BarPercent int    `json:"barPercent"`
+}
+
+// The year in review report
+type YearInReview struct {
+	Year          int              `json:"year"`
+	GeneratedAt   string           `json:"generatedAt"`
+	BooksFinished int              `json:"booksFinished"`
+	TotalPages    int              `json:"totalPages"`
+	LongestBook   *ReviewedBook    `json:"longestBook"`
+	ShortestBook  *ReviewedBook    `json:"shortestBook"`
+	FastestRead   *ReviewedBook    `json:"fastestRead"`
+	TopAuthors    []ReviewedAuthor `json:"topAuthors"`
+	Monthly       []ReviewedMonth  `json:"monthly"`
+	Books         []ReviewedBook   `json:"books"`
+}
+
+// Defining Query Parameters for getYearInReview(). It requires 1 Query Parameter year, format is optional and can be html, the default, markdown or json.
+type GetYearInReviewParameters struct {
+	Year   int    `form:"year" binding:"required"`
+	Format string `form:"format"`
+}
+
+// Returns a report of the Books finished in a year, as a standalone HTML page, as Markdown, or as JSON
+// It has the Books finished, the total pages, the longest and shortest Books, the fastest read, the top authors and the Books and pages finished each month
+func getYearInReview(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetYearInReviewParameters
+	var getYearInReviewParameters GetYearInReviewParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&getYearInReviewParameters) != nil || getYearInReviewParameters.Year < 1970 || getYearInReviewParameters.Year > 9999 {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide the year in YYYY format"})
+		return
+	}
+	format := getYearInReviewParameters.Format
+	if format != "" && format != "html" && format != "markdown" && format != "json" {
+		c.JSON(400, gin.H{"status": "Incorrect format, format should be html, markdown or json"})
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB for the user's Books finished in the year, Dates are stored as Epoch time at the start of the day
+	yearStart := time.Date(getYearInReviewParameters.Year, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+	yearEnd := time.Date(getYearInReviewParameters.Year+1, time.January, 1, 0, 0, 0, 0, time.UTC).Unix() - 1
+	queryToGetFinishedBooks := `SELECT ID, BOOK, AUTHOR, TOTALPAGES, DATESTARTED, DATEFINISHED FROM BOOKMANAGEMENT
+	WHERE ` + finishedBooksCondition + ` AND DATEFINISHED BETWEEN $1 AND $2 AND USERID = $3 ORDER BY DATEFINISHED, BOOK;`
+	result, err := db.Query(queryToGetFinishedBooks, yearStart, yearEnd, currentUserID(c))
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	yearInReview := YearInReview{Year: getYearInReviewParameters.Year, GeneratedAt: time.Now().Format("02-Jan-2006"), TopAuthors: []ReviewedAuthor{},
+		Books: []ReviewedBook{}}
+	for month := time.January; month <= time.December; month++ {
+		yearInReview.Monthly = append(yearInReview.Monthly, ReviewedMonth{Month: month.String()[:3]})
+	}
+	reviewedAuthors := map[string]*ReviewedAuthor{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and variables to hold the dates in Epoch time
+		reviewedBook := ReviewedBook{}
+		var dateStarted, dateFinished int64
+
+		// Scan the results into the struct
+		result.Scan(&reviewedBook.ID, &reviewedBook.Book, &reviewedBook.Author, &reviewedBook.TotalPages, &dateStarted, &dateFinished)
+
+		// Calculating the days in which the Book was read, same as getAllFinishedBooks(), a Book started and finished on the same day took 1 day
+		reviewedBook.DaysRead = (dateFinished - dateStarted) / 86400
+		if reviewedBook.DaysRead == 0 {
+			reviewedBook.DaysRead = 1
+		}
+		reviewedBook.DateStarted = convertEpochToDate(int(dateStarted))
+		reviewedBook.DateFinished = convertEpochToDate(int(dateFinished))
+
+		// Add the Book to the totals, its month and its author
+		yearInReview.BooksFinished++
+		yearInReview.TotalPages += reviewedBook.TotalPages
+		month := time.Unix(dateFinished, 0).UTC().Month()
+		yearInReview.Monthly[month-1].Books++
+		yearInReview.Monthly[month-1].Pages += reviewedBook.TotalPages
+		if reviewedAuthors[reviewedBook.Author] == nil {
+			reviewedAuthors[reviewedBook.Author] = &ReviewedAuthor{Author: reviewedBook.Author}
+		}
+		reviewedAuthors[reviewedBook.Author].Books++
+		reviewedAuthors[reviewedBook.Author].Pages += reviewedBook.TotalPages
+
+		// Append to the slice
+		yearInReview.Books = append(yearInReview.Books, reviewedBook)
+	}
+
+	// Find the longest and shortest Books, and the fastest read, which is the fewest days, or the most pages if the days are the same
+	for index := range yearInReview.Books {
+		reviewedBook := &yearInReview.Books[index]
+		if yearInReview.LongestBook == nil || reviewedBook.TotalPages > yearInReview.LongestBook.TotalPages {
+			yearInReview.LongestBook = reviewedBook
+		}
+		if yearInReview.ShortestBook == nil || reviewedBook.TotalPages < yearInReview.ShortestBook.TotalPages {
+			yearInReview.ShortestBook = reviewedBook
+		}
+		if yearInReview.FastestRead == nil || reviewedBook.DaysRead < yearInReview.FastestRead.DaysRead ||
+			(reviewedBook.DaysRead == yearInReview.FastestRead.DaysRead && reviewedBook.TotalPages > yearInReview.FastestRead.TotalPages) {
+			yearInReview.FastestRead = reviewedBook
+		}
+	}
+
+	// The top authors have the most Books, then the most pages
+	for _, reviewedAuthor := range reviewedAuthors {
+		yearInReview.TopAuthors = append(yearInReview.TopAuthors, *reviewedAuthor)
+	}
+	sort.Slice(yearInReview.TopAuthors, func(i, j int) bool {
+		first, second := yearInReview.TopAuthors[i], yearInReview.TopAuthors[j]
+		if first.Books != second.Books {
+			return first.Books > second.Books
+		}
+		if first.Pages != second.Pages {
+			return first.Pages > second.Pages
+		}
+		return first.Author < second.Author
+	})
+	if len(yearInReview.TopAuthors) > topAuthorsCount {
+		yearInReview.TopAuthors = yearInReview.TopAuthors[:topAuthorsCount]
+	}
+
+	// The chart bars are scaled to the month with the most pages
+	busiestMonthPages := 0
+	for _, reviewedMonth := range yearInReview.Monthly {
+		busiestMonthPages = max(busiestMonthPages, reviewedMonth.Pages)
+	}
+	for index := range yearInReview.Monthly {
+		if busiestMonthPages > 0 {
+			yearInReview.Monthly[index].BarPercent = yearInReview.Monthly[index].Pages * 100 / busiestMonthPages
+		}
+	}
+
+	// Return the report in the requested format
+	switch format {
+	case "json":
+		c.JSON(200, yearInReview)
+	case "markdown":
+		report, err := renderYearInReviewMarkdown(yearInReview)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not create the report"})
+			return
+		}
+		c.Header("Content-Disposition", `inline; filename="year-in-review-`+strconv.Itoa(yearInReview.Year)+`.md"`)
+		c.Data(200, "text/markdown; charset=utf-8", report)
+	default:
+		report, err := renderYearInReviewHTML(yearInReview)
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not create the report"})
+			return
+		}
+		c.Data(200, "text/html; charset=utf-8", report)
+	}
+
+}
+
+// Renders the year in review report as a standalone HTML page, the text is escaped by the template
+func renderYearInReviewHTML(yearInReview YearInReview) ([]byte, error) {
+
+	reportTemplate, err := htmlTemplate.ParseFS(yearInReviewTemplates, "templates/year_in_review.html")
+	if err != nil {
+		return nil, err
+	}
+
+	var report bytes.Buffer
+	err = reportTemplate.Execute(&report, yearInReview)
+
+	return report.Bytes(), err
+
+}
+
+// Renders the year in review report as Markdown
+// Characters which have a meaning in Markdown are escaped, and the chart bars are drawn with block characters
+func renderYearInReviewMarkdown(yearInReview YearInReview) ([]byte, error) {
+
+	markdownFunctions := textTemplate.FuncMap{
+		"escape": func(text string) string {
+			return strings.NewReplacer(`\`, `\\`, `|`, `\|`, `*`, `\*`, `_`, `\_`, "`", "\\`", `[`, `\[`, `]`, `\]`, `<`, `&lt;`, `>`, `&gt;`).Replace(text)
+		},
+		"bar": func(percent int) string {
+			return strings.Repeat("█", (percent+4)/5)
+		},
+	}
+	reportTemplate, err := textTemplate.New("year_in_review.md").Funcs(markdownFunctions).ParseFS(yearInReviewTemplates, "templates/year_in_review.md")
+	if err != nil {
+		return nil, err
+	}
+
+	var report bytes.Buffer
+	err = reportTemplate.Execute(&report, yearInReview)
+
+	return report.Bytes(), err
+
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Queries for the state of a Book kept in the audit log, by the table they read
+// The Book is looked up in its library, or in its library's trash, by its ID and the ID of its library, the other tables by its ID
+var queriesToGetBook = map[string]string{
+	"book":  `SELECT * FROM BOOKMANAGEMENT WHERE ID = $1 AND USERID = $2;`,
+	"trash": `SELECT * FROM BOOKTRASH WHERE ID = $1 AND USERID = $2;`,
+}
+var queriesToGetBookDetails = map[string]string{
+	"ownership": `SELECT * FROM BOOKOWNERSHIP WHERE BOOKID = $1;`,
+	"metadata":  `SELECT * FROM BOOKMETADATA WHERE BOOKID = $1;`,
+	"cover":     `SELECT * FROM BOOKCOVERS WHERE BOOKID = $1;`,
+	"genres":    `SELECT * FROM BOOKGENRES WHERE BOOKID = $1 ORDER BY GENREID;`,
+	"loans":     `SELECT * FROM BOOKLOANS WHERE BOOKID = $1 ORDER BY DATELENT;`,
+	"relations": `SELECT * FROM BOOKRELATIONS WHERE BOOKID = $1 OR RELATEDBOOKID = $1 ORDER BY ID;`,
+	"addition":  `SELECT * FROM BOOKADDITIONS WHERE BOOKID = $1;`,
+}
+
+// Endpoints which can change many Books at once, each Book they change gets its own entry in the audit log, with its state before and after
+// The restores can change the Books of every library, the others only change the Books of the library they are made in
+var bulkChangeEndpoints = map[string]bool{
+	"/importCSV":       false,
+	"/importGoodreads": false,
+	"/importCalibre":   false,
+	"/deleteGenre":     false,
+	"/restoreBackup":   true,
+	"/restoreSnapshot": true,
+}
+
+// Changes made by the server itself, like purging the Books which have been in the trash for too long, are recorded as made by this user
+const systemUserID = "system"
+
+// An entry to be added to the audit log, for the library the Book is in
+type auditEntry struct {
+	libraryID   string
+	bookID      string
+	stateBefore []byte
+	stateAfter  []byte
+}
+
+// Records every change made through the endpoints of a library in the audit log, with who made it, when, with which endpoint, and to which Book
+// When the change is to a Book, the Book's state before and after it is kept too, changes to many Books keep the state of each Book they change,
+// and changes to a Genre keep the Genre's state
+// Only changes which succeed are recorded, requests which are rejected change nothing
+// The response is held back until the change is recorded, if it cannot be recorded, the response is replaced with a 500 error
+func recordAuditLog(c *gin.Context) {
+
+	if c.Request.Method == "GET" || c.Request.Method == "HEAD" || c.Request.Method == "OPTIONS" {
+		c.Next()
+		return
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, the change is not made, as it could not be recorded
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Get the state before the change, of every Book it could change or of the Book the request is for, and of the Genre it is for
+	bookID := requestBookID(c, db)
+	genreID := requestParameter(c, "genreID")
+	wholeDB, isBulkChange := bulkChangeEndpoints[c.FullPath()]
+	var stateBefore, genreStateBefore []byte
+	var bookStatesBefore map[string]auditEntry
+	if isBulkChange {
+		bookStatesBefore = getBookStates(db, currentUserID(c), wholeDB)
+	} else if bookID != "" {
+		stateBefore = getBookState(db, bookID, currentUserID(c))
+	}
+	if genreID != "" {
+		genreStateBefore = getGenreState(db, genreID, currentUserID(c))
+	}
+
+	// Hold back the response, so the ID of a Book or Genre which is added can be read from it, and it can be replaced if the change is not recorded
+	responseWriter := &auditResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = responseWriter
+	defer func() {
+		c.Writer = responseWriter.ResponseWriter
+		c.Writer.Write(responseWriter.body.Bytes())
+	}()
+
+	c.Next()
+
+	if c.Writer.Status() >= 400 {
+		return
+	}
+
+	// Get the state after the change, for a Book or Genre which was added its ID is in the response
+	var response struct {
+		BookID  string `json:"bookID"`
+		GenreID string `json:"genreID"`
+	}
+	json.Unmarshal(responseWriter.body.Bytes(), &response)
+	auditEntries := []auditEntry{}
+	if bookID == "" {
+		bookID = response.BookID
+	}
+	if isBulkChange {
+		auditEntries = changedBookEntries(bookStatesBefore, getBookStates(db, currentUserID(c), wholeDB))
+	} else if bookID != "" {
+		auditEntries = append(auditEntries, auditEntry{currentUserID(c), bookID, stateBefore, getBookState(db, bookID, currentUserID(c))})
+	}
+
+	// A change to a Genre gets an entry of its own, besides the entries of the Books which were in it
+	if genreID == "" {
+		genreID = response.GenreID
+	}
+	if genreID != "" {
+		if genreStateAfter := getGenreState(db, genreID, currentUserID(c)); !bytes.Equal(genreStateBefore, genreStateAfter) {
+			auditEntries = append(auditEntries, auditEntry{currentUserID(c), "", genreStateBefore, genreStateAfter})
+		}
+	}
+
+	// Every change gets at least one entry, even if it did not change any Book
+	if len(auditEntries) == 0 {
+		auditEntries = append(auditEntries, auditEntry{libraryID: currentUserID(c)})
+	}
+
+	if err = addAuditEntries(db, c, auditEntries); err != nil {
+		log.Println("Could not record a change in the audit log, ", err)
+		responseWriter.body.Reset()
+		c.Writer.WriteHeader(500)
+		responseBody, _ := json.Marshal(gin.H{"status": "The change was made, but could not be recorded in the audit log"})
+		responseWriter.body.Write(responseBody)
+	}
+
+}
+
+// Adds entries to the audit log in a single transaction, so a change is either recorded in full or not at all
+// The log is only ever added to, entries are never changed or deleted
+func addAuditEntries(db *sql.DB, c *gin.Context, auditEntries []auditEntry) error {
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	if err = insertAuditEntries(transaction, signedInUserID(c), c.GetString("apiKeyID"), c.Request.Method, c.FullPath(), auditEntries); err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+
+}
+
+// Adds entries to the audit log as part of a transaction, for a change made by a user, with an API key if one was used, through an endpoint
+// Changes made by the server itself use it directly, so they are recorded in the same transaction as the change
+func insertAuditEntries(transaction *sql.Tx, userID string, apiKeyID string, method string, endpoint string, auditEntries []auditEntry) error {
+
+	date := time.Now().Unix()
+	queryToAddAuditEntry := `INSERT INTO AUDITLOG(ID, LIBRARYID, USERID, APIKEYID, DATE, METHOD, ENDPOINT, BOOKID, BEFORE, AFTER) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+	for _, entry := range auditEntries {
+		_, err := transaction.Exec(queryToAddAuditEntry, uniqueIDGenerator(), entry.libraryID, userID, apiKeyID, date, method, endpoint, entry.bookID,
+			string(entry.stateBefore), string(entry.stateAfter))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Holds back the response body as it is written, it is written out once the change is recorded
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *auditResponseWriter) Write(data []byte) (int, error) {
+
+	return writer.body.Write(data)
+
+}
+
+func (writer *auditResponseWriter) WriteString(data string) (int, error) {
+
+	return writer.body.WriteString(data)
+
+}
+
+// Returns the ID of the Book a request is for, from its bookID parameter in the query, the JSON body or the form
+// For requests about a relation, the Book it belongs to is looked up, other requests are not for a single Book and return an empty string
+func requestBookID(c *gin.Context, db *sql.DB) string {
+
+	// The Book is only worked out once for each request
+	if bookID, found := c.Get("bookID"); found {
+		return bookID.(string)
+	}
+	bookID := requestParameter(c, "bookID")
+
+	if relationID := c.Query("relationID"); bookID == "" && relationID != "" {
+		db.QueryRow(`SELECT BOOKID FROM BOOKRELATIONS WHERE ID = $1;`, relationID).Scan(&bookID)
+	}
+	c.Set("bookID", bookID)
+
+	return bookID
+
+}
+
+// Returns a string parameter of a request, from the query, the JSON body or the form
+func requestParameter(c *gin.Context, name string) string {
+
+	if value := c.Query(name); value != "" {
+		return value
+	}
+
+	// The JSON body is read and put back, so the endpoint can still read it
+	if c.ContentType() == "application/json" && c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		var parameters map[string]any
+		if err == nil && json.Unmarshal(body, &parameters) == nil {
+			value, _ := parameters[name].(string)
+			return value
+		}
+		return ""
+	}
+
+	return c.PostForm(name)
+
+}
+
+// Returns the state of every Book in a library and its trash, or in every library, keyed by the Book's ID, along with the library the Book is in
+func getBookStates(db *sql.DB, libraryID string, wholeDB bool) map[string]auditEntry {
+
+	queryToGetBooks := `SELECT ID, USERID FROM BOOKMANAGEMENT WHERE $1 OR USERID = $2 UNION SELECT ID, USERID FROM BOOKTRASH WHERE $1 OR USERID = $2;`
+	books := map[string]string{}
+	for _, row := range getStateRows(db, queryToGetBooks, wholeDB, libraryID) {
+		bookID, _ := row["ID"].(string)
+		bookLibraryID, _ := row["USERID"].(string)
+		books[bookID] = bookLibraryID
+	}
+
+	bookStates := map[string]auditEntry{}
+	for bookID, bookLibraryID := range books {
+		bookStates[bookID] = auditEntry{libraryID: bookLibraryID, bookID: bookID, stateBefore: getBookState(db, bookID, bookLibraryID)}
+	}
+
+	return bookStates
+
+}
+
+// Returns an entry for each Book whose state is not the same before and after a change, in the order of their IDs
+// Books which were added or deleted by the change only have a state after or before it
+func changedBookEntries(statesBefore map[string]auditEntry, statesAfter map[string]auditEntry) []auditEntry {
+
+	bookIDs := []string{}
+	for bookID := range statesBefore {
+		bookIDs = append(bookIDs, bookID)
+	}
+	for bookID := range statesAfter {
+		if _, found := statesBefore[bookID]; !found {
+			bookIDs = append(bookIDs, bookID)
+		}
+	}
+	sort.Strings(bookIDs)
+
+	changedEntries := []auditEntry{}
+	for _, bookID := range bookIDs {
+		before, after := statesBefore[bookID], statesAfter[bookID]
+		if bytes.Equal(before.stateBefore, after.stateBefore) {
+			continue
+		}
+		libraryID := after.libraryID
+		if libraryID == "" {
+			libraryID = before.libraryID
+		}
+		changedEntries = append(changedEntries, auditEntry{libraryID, bookID, before.stateBefore, after.stateBefore})
+	}
+
+	return changedEntries
+
+}
+
+// Returns the state of a Genre in its library as JSON
+// Returns nil if the Genre is not in the library
+func getGenreState(db *sql.DB, genreID string, libraryID string) []byte {
+
+	rows := getStateRows(db, `SELECT * FROM GENRES WHERE ID = $1 AND USERID = $2;`, genreID, libraryID)
+	if len(rows) == 0 {
+		return nil
+	}
+	state, _ := json.Marshal(map[string]any{"genre": rows[0]})
+
+	return state
+
+}
+
+// Returns the state of a Book in its library as JSON, with its rows from each table which has its details
+// Returns nil if the Book is not in the library or its trash
+func getBookState(db *sql.DB, bookID string, libraryID string) []byte {
+
+	bookState := map[string]any{}
+	for table, queryToGetBook := range queriesToGetBook {
+		if rows := getStateRows(db, queryToGetBook, bookID, libraryID); len(rows) > 0 {
+			bookState[table] = rows[0]
+		}
+	}
+	if len(bookState) == 0 {
+		return nil
+	}
+
+	// Tables with a single row for each Book keep the row, the others keep their rows in a list
+	for table, queryToGetBookDetails := range queriesToGetBookDetails {
+		rows := getStateRows(db, queryToGetBookDetails, bookID)
+		switch {
+		case table == "genres" || table == "loans" || table == "relations":
+			bookState[table] = rows
+		case len(rows) > 0:
+			bookState[table] = rows[0]
+		}
+	}
+	state, _ := json.Marshal(bookState)
+
+	return state
+
+}
+
+// Returns the rows of a query, each with its values by their columns, so the state keeps every column the tables have
+func getStateRows(db *sql.DB, query string, parameters ...any) []map[string]any {
+
+	rows := []map[string]any{}
+	result, err := db.Query(query, parameters...)
+	if err != nil {
+		return rows
+	}
+	defer result.Close()
+	columns, _ := result.Columns()
+
+	for result.Next() {
+		values := make([]any, len(columns))
+		valuePointers := make([]any, len(columns))
+		for i := range values {
+			valuePointers[i] = &values[i]
+		}
+		result.Scan(valuePointers...)
+		row := map[string]any{}
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+
+}
+
+// Defining JSON body for getAuditLog(). It has 4 optional Query Parameters bookID, username, fromDate, toDate.
+type GetAuditLogParameters struct {
+	BookID   string `form:"bookID"`
+	Username string `form:"username"`
+	FromDate string `form:"fromDate"`
+	ToDate   string `form:"toDate"`
+}
+
+// Returns the audit log of the library, newest first, for a Book, changes made by a user, or changes made between two dates
+func getAuditLog(c *gin.Context) {
+
+	// Variables for DB and Error
+	var db *sql.DB
+	var err error
+
+	// Creating an instance of the struct, GetAuditLogParameters
+	var getAuditLogParameters GetAuditLogParameters
+
+	// Bind to the struct's members. If any member is invalid, binding does not happen and an error will be returned. Then its rejected with 400
+	if c.ShouldBindQuery(&getAuditLogParameters) != nil {
+		c.JSON(400, gin.H{"status": "Incorrect parameters, please provide all required parameters"})
+		return
+	}
+
+	// Checks if the supplied dates are in DD-MMM-YYYY format, the period includes the whole of toDate
+	fromDate, toDate := 0, int(time.Now().Unix())
+	if getAuditLogParameters.FromDate != "" {
+		if !checkDateFormat(getAuditLogParameters.FromDate) {
+			c.JSON(400, gin.H{"status": "Incorrect Date format, Date should be in DD-MMM-YYYY format, e.g., 27-Aug-2024"})
+			return
+		}
+		fromDate = convertDateToEpoch(getAuditLogParameters.FromDate)
+	}
+	if getAuditLogParameters.ToDate != "" {
+		if !checkDateFormat(getAuditLogParameters.ToDate) {
+			c.JSON(400, gin.H{"status": "Incorrect Date format, Date should be in DD-MMM-YYYY format, e.g., 27-Aug-2024"})
+			return
+		}
+		toDate = convertDateToEpoch(getAuditLogParameters.ToDate) + 24*60*60 - 1
+	}
+
+	// Connect to the DB. If there is any issue connecting to the DB, throw a 500 error and return
+	db, err = sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not connect to DB"})
+		return
+	}
+	defer db.Close()
+
+	// Query the DB and result is held into the variable, result, the Book and user are only filtered on if they are supplied
+	// Changes made by the server itself are shown as made by the system user
+	queryToGetAuditLog := `SELECT AUDITLOG.ID, COALESCE(USERS.USERNAME, CASE AUDITLOG.USERID WHEN $6 THEN $6 ELSE '' END), AUDITLOG.APIKEYID, AUDITLOG.DATE, AUDITLOG.METHOD, AUDITLOG.ENDPOINT, AUDITLOG.BOOKID,
+	AUDITLOG.BEFORE, AUDITLOG.AFTER FROM AUDITLOG LEFT JOIN USERS ON USERS.ID = AUDITLOG.USERID
+	WHERE AUDITLOG.LIBRARYID = $1 AND ($2 = '' OR AUDITLOG.BOOKID = $2) AND ($3 = '' OR USERS.USERNAME = $3) AND AUDITLOG.DATE BETWEEN $4 AND $5
+	ORDER BY AUDITLOG.DATE DESC, AUDITLOG.ROWID DESC;`
+	result, err := db.Query(queryToGetAuditLog, currentUserID(c), getAuditLogParameters.BookID, getAuditLogParameters.Username, fromDate, toDate, systemUserID)
+	if err != nil {
+		c.JSON(500, gin.H{"status": "Could not execute Query"})
+		return
+	}
+	defer result.Close()
+
+	// Defining a struct to hold all the values from the Query result
+	type GetAuditEntryDetails struct {
+		ID       string          `json:"id"`
+		Username string          `json:"username"`
+		APIKeyID string          `json:"apiKeyID"`
+		Date     string          `json:"date"`
+		Method   string          `json:"method"`
+		Endpoint string          `json:"endpoint"`
+		BookID   string          `json:"bookID"`
+		Before   json.RawMessage `json:"before"`
+		After    json.RawMessage `json:"after"`
+	}
+
+	// Creating a slice from the struct
+	auditLog := []GetAuditEntryDetails{}
+
+	// Iterating over the results
+	for result.Next() {
+
+		//Creating a new struct and variables to hold the date in Epoch time and the states
+		auditEntry := GetAuditEntryDetails{}
+		var date int64
+		var before, after string
+
+		// Scan the results into the struct
+		result.Scan(&auditEntry.ID, &auditEntry.Username, &auditEntry.APIKeyID, &date, &auditEntry.Method, &auditEntry.Endpoint, &auditEntry.BookID, &before, &after)
+
+		// The date is returned in RFC 3339 format, and a state which was not kept is returned as null
+		auditEntry.Date = formatAPIKeyTime(date)
+		auditEntry.Before = json.RawMessage("null")
+		auditEntry.After = json.RawMessage("null")
+		if before != "" {
+			auditEntry.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			auditEntry.After = json.RawMessage(after)
+		}
+
+		// Append to the slice
+		auditLog = append(auditLog, auditEntry)
+	}
+
+	// Returning all the data
+	c.JSON(200, gin.H{"auditLog": auditLog})
+
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Origins which browsers can call the API from, separated by commas, set by CORS_ALLOWED_ORIGINS
+// * allows every origin, and CORS is turned off if it is not set
+var corsAllowedOrigins = splitSetting(getTextSettingFromEnvironment("CORS_ALLOWED_ORIGINS", ""))
+
+// Methods and request headers browsers can send, separated by commas, set by CORS_ALLOWED_METHODS and CORS_ALLOWED_HEADERS
+var corsAllowedMethods = getTextSettingFromEnvironment("CORS_ALLOWED_METHODS", "GET, POST, DELETE, OPTIONS")
+var corsAllowedHeaders = getTextSettingFromEnvironment("CORS_ALLOWED_HEADERS", "Authorization, Content-Type, If-Match, If-None-Match, X-API-Key, X-Library")
+
+// Response headers browsers let clients read, separated by commas, set by CORS_EXPOSED_HEADERS
+var corsExposedHeaders = getTextSettingFromEnvironment("CORS_EXPOSED_HEADERS", "Content-Disposition, ETag, Retry-After")
+
+// Whether browsers can send cookies and HTTP Basic credentials, set by CORS_ALLOW_CREDENTIALS, 1 allows them, defaults to 0
+var corsAllowCredentials = getSettingFromEnvironment("CORS_ALLOW_CREDENTIALS", 0) == 1
+
+// Seconds browsers can cache a preflight response for, set by CORS_MAX_AGE, defaults to 600
+var corsMaxAge = getSettingFromEnvironment("CORS_MAX_AGE", 600)
+
+// Splits a setting separated by commas, dropping the spaces around each value and empty values
+func splitSetting(setting string) []string {
+
+	values := []string{}
+	for _, value := range strings.Split(setting, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+
+}
+
+// Adds the CORS headers for requests from allowed origins, and answers preflight requests for every route with 204
+// Preflight requests are answered before signing in, as browsers send them without credentials
+// Requests from other origins get no CORS headers, and their preflight requests are rejected with 403
+func handleCORS(c *gin.Context) {
+
+	origin := c.GetHeader("Origin")
+	if len(corsAllowedOrigins) == 0 || origin == "" {
+		c.Next()
+		return
+	}
+
+	// The response depends on the origin, so caches should keep one for each origin
+	c.Writer.Header().Add("Vary", "Origin")
+	isPreflight := c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != ""
+	if !slices.Contains(corsAllowedOrigins, "*") && !slices.Contains(corsAllowedOrigins, origin) {
+		if isPreflight {
+			c.AbortWithStatusJSON(403, gin.H{"status": "Origin " + origin + " is not allowed"})
+			return
+		}
+		c.Next()
+		return
+	}
+
+	// The origin is sent back instead of *, as browsers do not accept * with credentials
+	c.Header("Access-Control-Allow-Origin", origin)
+	if corsAllowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+
+	if isPreflight {
+		c.Header("Access-Control-Allow-Methods", corsAllowedMethods)
+		c.Header("Access-Control-Allow-Headers", corsAllowedHeaders)
+		c.Header("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+		c.AbortWithStatus(204)
+		return
+	}
+	if corsExposedHeaders != "" {
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+	}
+
+	c.Next()
+
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -77,3 +79,49 @@ func convertEpochToDate(epochTime int) string {
 	return t.Format("02-Jan-2006")
 
 }
+
+// Returns today's date as Epoch Time, at the start of the day, the same way dates in DD-MMM-YYYY format are stored
+func todaysDateInEpoch() int {
+
+	return convertDateToEpoch(time.Now().Format("02-Jan-2006"))
+
+}
+
+// Performs sanitization on an optional string, using sanitizeString()
+// Returns nil if the string is not supplied, so it can be used with COALESCE to keep an existing value
+func sanitizeOptionalString(stringToSanitize *string) *string {
+
+	if stringToSanitize == nil {
+		return nil
+	}
+
+	sanitizedString := sanitizeString(*stringToSanitize)
+	return &sanitizedString
+
+}
+
+// Reads a whole number setting from an environment variable
+// Returns the default value if the variable is not set, or is not a whole number
+func getSettingFromEnvironment(name string, defaultValue int) int {
+
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+
+}
+
+// Reads a text setting from an environment variable
+// Returns the default value if the variable is not set
+func getTextSettingFromEnvironment(name string, defaultValue string) string {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"database/sql"
+	"log"
+	"time"
 
 	_ "modernc.org/sqlite"
 
@@ -10,26 +12,118 @@ import (
 
 func main() {
 
+	createTables()
+	loadJSONWebKeys()
+	go scheduleSnapshots()
+	go schedulePurges()
+
 	request := gin.Default()
+
+	// The client IP is only taken from X-Forwarded-For when the request comes through a trusted proxy
+	if err := request.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Could not set the trusted proxies, ", err)
+	}
+
+	// CORS headers are added first, so browsers can also read the responses of rejected requests
+	// Every request is rate limited by its client IP and its body size is limited
+	request.Use(handleCORS, limitRequestsPerIP, limitRequestBodySize)
+
 	request.GET("/", landingPage)
-	request.POST("/addABook", addABook)
-	request.POST("/updateBookDetails", updateBookDetails)
-	request.POST("/startABook", startABook)
-	request.POST("/finishABook", finishABook)
-	request.POST("/updateABook", updateABook)
-	request.POST("/restartABook", restartABook)
-	request.POST("/addNote", addNote)
-	request.POST("/addToANote", addToANote)
-	request.GET("/getBookID", getBookID)
-	request.GET("/getBookDetails", getBookDetails)
-	request.GET("/getAllBooks", getAllBooks)
-	request.GET("/getAllUnreadBooks", getAllUnreadBooks)
-	request.GET("/getAllReadingBooks", getAllReadingBooks)
-	request.GET("/getAllFinishedBooks", getAllFinishedBooks)
-	request.GET("/getBooksByAuthor", getBooksByAuthor)
-	request.GET("/getBooksReadInAPeriod", getBooksReadInAPeriod)
-	request.GET("/getBookContaining", getBookContaining)
-	request.DELETE("/deleteBook", deleteBook)
+	request.POST("/registerUser", registerUser)
+
+	// Share tokens give read only access to a few endpoints of a library, without signing in
+	shared := request.Group("/shared", authenticateShareToken)
+	shared.GET("/getAllBooks", getAllBooks)
+	shared.GET("/getAllFinishedBooks", getAllFinishedBooks)
+	shared.GET("/getGenreStats", getGenreStats)
+	shared.GET("/getYearInReview", getYearInReview)
+
+	// Every other endpoint needs a signed in user, and works on that user's library, or a library shared with them
+	// Signed in with an API key, the GET endpoints need the read scope and the others need the write scope, and requests are rate limited by the key
+	// Changes to the same Book are made one at a time and checked against the version in If-Match, and every change made through them is recorded in the library's audit log
+	library := request.Group("/", authenticateUser, limitRequestsPerAPIKey, selectLibrary, requireScopeForMethod, lockBook, recordAuditLog, checkBookVersion)
+	library.POST("/addABook", addABook)
+	library.POST("/updateBookDetails", updateBookDetails)
+	library.POST("/startABook", startABook)
+	library.POST("/finishABook", finishABook)
+	library.POST("/updateABook", updateABook)
+	library.POST("/restartABook", restartABook)
+	library.POST("/addNote", addNote)
+	library.POST("/addToANote", addToANote)
+	library.POST("/lendABook", lendABook)
+	library.POST("/returnABook", returnABook)
+	library.POST("/uploadCover", uploadCover)
+	library.POST("/addAGenre", addAGenre)
+	library.POST("/updateGenre", updateGenre)
+	library.POST("/assignGenre", assignGenre)
+	library.POST("/unassignGenre", unassignGenre)
+	library.POST("/relateBooks", relateBooks)
+	library.POST("/importCSV", importCSV)
+	library.POST("/importGoodreads", importGoodreads)
+	library.POST("/applyBookMetadata", applyBookMetadata)
+	library.POST("/trackABook", trackABook)
+	library.POST("/createAPIKey", createAPIKey)
+	library.POST("/revokeAPIKey", revokeAPIKey)
+	library.POST("/restoreBook", restoreBook)
+	library.POST("/undoChange", undoChange)
+	library.GET("/getBookID", getBookID)
+	library.GET("/getBookDetails", getBookDetails)
+	library.GET("/getAllBooks", getAllBooks)
+	library.GET("/getAllUnreadBooks", getAllUnreadBooks)
+	library.GET("/getAllReadingBooks", getAllReadingBooks)
+	library.GET("/getAllFinishedBooks", getAllFinishedBooks)
+	library.GET("/getBooksByAuthor", getBooksByAuthor)
+	library.GET("/getBooksReadInAPeriod", getBooksReadInAPeriod)
+	library.GET("/getBookContaining", getBookContaining)
+	library.GET("/getLoanHistory", getLoanHistory)
+	library.GET("/getOutstandingLoans", getOutstandingLoans)
+	library.GET("/getOverdueLoans", getOverdueLoans)
+	library.GET("/getBooksByLocation", getBooksByLocation)
+	library.GET("/getLibraryValue", getLibraryValue)
+	library.GET("/getCover", getCover)
+	library.GET("/getGenreTree", getGenreTree)
+	library.GET("/getBooksInGenre", getBooksInGenre)
+	library.GET("/getGenreStats", getGenreStats)
+	library.GET("/getReadingTrail", getReadingTrail)
+	library.GET("/exportCSV", exportCSV)
+	library.GET("/opds", getOPDSCatalog)
+	library.GET("/opds/authors", getOPDSAuthors)
+	library.GET("/opds/books", getOPDSBooks)
+	library.GET("/getReadingCalendar", getReadingCalendar)
+	library.GET("/getRecentlyFinishedFeed", getRecentlyFinishedFeed)
+	library.GET("/getRecentlyAddedFeed", getRecentlyAddedFeed)
+	library.GET("/getYearInReview", getYearInReview)
+	library.GET("/lookupBookMetadata", lookupBookMetadata)
+	library.GET("/getCurrentUser", getCurrentUser)
+	library.GET("/getCatalog", getCatalog)
+	library.GET("/getAPIKeys", getAPIKeys)
+	library.GET("/getSharedLibraries", getSharedLibraries)
+	library.GET("/getTrash", getTrash)
+	library.GET("/getUndoableChanges", getUndoableChanges)
+	library.DELETE("/deleteBook", deleteBook)
+	library.DELETE("/deleteGenre", deleteGenre)
+	library.DELETE("/deleteRelation", deleteRelation)
+
+	// Endpoints which manage a library's members and share tokens, its audit log, and purging Books from its trash, are only for its owners
+	owner := library.Group("/", requireLibraryOwner)
+	owner.POST("/addLibraryMember", addLibraryMember)
+	owner.POST("/createShareToken", createShareToken)
+	owner.POST("/revokeShareToken", revokeShareToken)
+	owner.GET("/getLibraryMembers", getLibraryMembers)
+	owner.GET("/getShareTokens", getShareTokens)
+	owner.GET("/getAuditLog", getAuditLog)
+	owner.DELETE("/removeLibraryMember", removeLibraryMember)
+	owner.DELETE("/purgeBook", purgeBook)
+
+	// Endpoints which work on the whole DB are only for admins
+	admin := library.Group("/", requireAdmin)
+	admin.POST("/importCalibre", importCalibre)
+	admin.POST("/restoreBackup", restoreBackup)
+	admin.POST("/createSnapshot", createSnapshot)
+	admin.POST("/restoreSnapshot", restoreSnapshot)
+	admin.GET("/exportBackup", exportBackup)
+	admin.GET("/getSnapshots", getSnapshots)
+
 	request.Run(":8083")
 
 }
@@ -44,7 +138,7 @@ type DeleteBookDetailsParameters struct {
 	BookID string `form:"bookID" binding:"required"`
 }
 
-// Returns a single Book Details
+// Moves a Book to the trash, it is hidden until it is restored, and purged for good after TRASH_RETENTION_DAYS
 func deleteBook(c *gin.Context) {
 
 	// Variables for DB and Error
@@ -70,8 +164,8 @@ func deleteBook(c *gin.Context) {
 
 	// Check if the exists in the DB by querying using the ID
 	// Result is scanned into the variable, result
-	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID = $1;`
-	result := db.QueryRow(queryToCheckExistingBook, deleteBookDetailsParameters.BookID)
+	queryToCheckExistingBook := `SELECT ID FROM BOOKMANAGEMENT WHERE ID = $1 AND USERID = $2;`
+	result := db.QueryRow(queryToCheckExistingBook, deleteBookDetailsParameters.BookID, currentUserID(c))
 
 	// Defining a struct to hold all the values from the Query result
 	type GetBookDetails struct {
@@ -85,12 +179,35 @@ func deleteBook(c *gin.Context) {
 	result.Scan(&getBookDetails.ID)
 
 	// If the length of getBookDetails.ID is greater than 0, means the query returned a result, so there is a book by that ID
-	// We delete that book
+	// We move that book to the trash, its loans, location, cover, genres, relations and metadata are kept so it can be restored
 	// Else, its rejected with a 404 as there is no book by that ID
 	if len(getBookDetails.ID) > 0 {
+
+		// The Book is copied to the trash and deleted in a single transaction, so it is never lost or in both
+		transaction, err := db.Begin()
+		if err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		defer transaction.Rollback()
+
+		queryToMoveBookToTrash := `INSERT INTO BOOKTRASH (ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID, VERSION, DATEDELETED)
+		SELECT ID, BOOK, AUTHOR, TOTALPAGES, READPAGES, DATESTARTED, DATEFINISHED, NOTES, USERID, VERSION, $1 FROM BOOKMANAGEMENT WHERE ID = $2;`
 		queryToDeleteExistingBook := `DELETE FROM BOOKMANAGEMENT WHERE ID=$1;`
-		db.QueryRow(queryToDeleteExistingBook, deleteBookDetailsParameters.BookID)
-		c.JSON(200, gin.H{"status": "Book with ID, " + deleteBookDetailsParameters.BookID + " deleted."})
+		if _, err = transaction.Exec(queryToMoveBookToTrash, time.Now().Unix(), deleteBookDetailsParameters.BookID); err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		if _, err = transaction.Exec(queryToDeleteExistingBook, deleteBookDetailsParameters.BookID); err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+		if err = transaction.Commit(); err != nil {
+			c.JSON(500, gin.H{"status": "Could not execute Query"})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "Book with ID, " + deleteBookDetailsParameters.BookID + " moved to the trash."})
 
 	} else {
 		c.JSON(404, gin.H{"status": "No Book by ID, " + deleteBookDetailsParameters.BookID + " exists."})
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Requests allowed per minute from each client IP, and how many can be made at once, set by RATE_LIMIT_IP_PER_MINUTE and RATE_LIMIT_IP_BURST
+// A rate of 0 turns the limit off
+var rateLimitPerIP = getSettingFromEnvironment("RATE_LIMIT_IP_PER_MINUTE", 120)
+var rateLimitBurstPerIP = getSettingFromEnvironment("RATE_LIMIT_IP_BURST", 30)
+
+// Requests allowed per minute with each API key, and how many can be made at once, set by RATE_LIMIT_KEY_PER_MINUTE and RATE_LIMIT_KEY_BURST
+// A rate of 0 turns the limit off
+var rateLimitPerAPIKey = getSettingFromEnvironment("RATE_LIMIT_KEY_PER_MINUTE", 300)
+var rateLimitBurstPerAPIKey = getSettingFromEnvironment("RATE_LIMIT_KEY_BURST", 60)
+
+// Proxies whose X-Forwarded-For header is trusted for the client IP, as IPs or CIDRs separated by commas, set by TRUSTED_PROXIES
+// No proxy is trusted if it is not set, so clients cannot get around the limit for their IP by sending the header themselves
+var trustedProxies = splitSetting(getTextSettingFromEnvironment("TRUSTED_PROXIES", ""))
+
+// Largest request body allowed in MB, set by MAX_BODY_MB, defaults to 16, big enough for covers and CSV imports
+var maxBodyMB = getSettingFromEnvironment("MAX_BODY_MB", 16)
+
+// Largest request body allowed in MB for the admin restore endpoints, set by MAX_RESTORE_BODY_MB, defaults to 512
+// Backups hold every cover in base64, so they are much bigger than any other request body
+var maxRestoreBodyMB = getSettingFromEnvironment("MAX_RESTORE_BODY_MB", 512)
+
+// Endpoints which allow request bodies up to MAX_RESTORE_BODY_MB rather than MAX_BODY_MB
+var restoreEndpoints = map[string]bool{
+	"/restoreBackup": true,
+	"/importCalibre": true,
+}
+
+// Longest note a Book can have in characters, set by MAX_NOTE_LENGTH, defaults to 10000
+var maxNoteLength = getSettingFromEnvironment("MAX_NOTE_LENGTH", 10000)
+
+// A token bucket, it holds up to its burst of tokens and is refilled at its rate, each request takes a token
+type tokenBucket struct {
+	tokens      float64
+	lastRefill  time.Time
+	lastRequest time.Time
+}
+
+// A set of token buckets with the same rate and burst, one for each client IP or API key
+type rateLimiter struct {
+	ratePerSecond float64
+	burst         float64
+	buckets       map[string]*tokenBucket
+	lastCleanup   time.Time
+	mutex         sync.Mutex
+}
+
+// Creates a rate limiter which allows ratePerMinute requests per minute for each key, with up to burst of them at once
+func newRateLimiter(ratePerMinute int, burst int) *rateLimiter {
+
+	return &rateLimiter{ratePerSecond: float64(ratePerMinute) / 60, burst: float64(max(burst, 1)), buckets: map[string]*tokenBucket{}, lastCleanup: time.Now()}
+
+}
+
+// Takes a token from the key's bucket, if the bucket is empty, returns FALSE and how long until a token is available
+func (limiter *rateLimiter) allow(key string) (bool, time.Duration) {
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+
+	// Buckets which have not been used for long enough to have filled up are the same as new buckets, so they are dropped once a minute
+	if now.Sub(limiter.lastCleanup) > time.Minute {
+		fullAfter := time.Duration(limiter.burst / limiter.ratePerSecond * float64(time.Second))
+		for bucketKey, bucket := range limiter.buckets {
+			if now.Sub(bucket.lastRequest) > fullAfter {
+				delete(limiter.buckets, bucketKey)
+			}
+		}
+		limiter.lastCleanup = now
+	}
+
+	// Refill the bucket for the time since it was last refilled, new buckets start full
+	bucket, found := limiter.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: limiter.burst, lastRefill: now}
+		limiter.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+now.Sub(bucket.lastRefill).Seconds()*limiter.ratePerSecond)
+	bucket.lastRefill = now
+	bucket.lastRequest = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limiter.ratePerSecond * float64(time.Second))
+	}
+	bucket.tokens--
+
+	return true, 0
+
+}
+
+// Rate limiters for the client IPs and the API keys
+var ipRateLimiter = newRateLimiter(rateLimitPerIP, rateLimitBurstPerIP)
+var apiKeyRateLimiter = newRateLimiter(rateLimitPerAPIKey, rateLimitBurstPerAPIKey)
+
+// Limits the requests from each client IP, if there are too many, its rejected with 429
+func limitRequestsPerIP(c *gin.Context) {
+
+	if rateLimitPerIP > 0 {
+		limitRequests(c, ipRateLimiter, c.ClientIP())
+		return
+	}
+
+	c.Next()
+
+}
+
+// Limits the requests made with each API key, requests which are not made with an API key are not limited here
+// If there are too many, its rejected with 429
+func limitRequestsPerAPIKey(c *gin.Context) {
+
+	if apiKeyID := c.GetString("apiKeyID"); rateLimitPerAPIKey > 0 && apiKeyID != "" {
+		limitRequests(c, apiKeyRateLimiter, apiKeyID)
+		return
+	}
+
+	c.Next()
+
+}
+
+// Takes a token for the key from the rate limiter, if there is none, its rejected with 429 and Retry-After has the seconds until there is one
+func limitRequests(c *gin.Context, limiter *rateLimiter, key string) {
+
+	allowed, retryAfter := limiter.allow(key)
+	if !allowed {
+		retryAfterSeconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+		c.Header("Retry-After", retryAfterSeconds)
+		c.AbortWithStatusJSON(429, gin.H{"status": "Too many requests, please try again in " + retryAfterSeconds + " seconds"})
+		return
+	}
+
+	c.Next()
+
+}
+
+// Limits the size of request bodies to MAX_BODY_MB, or MAX_RESTORE_BODY_MB for the restore endpoints, bodies which say they are bigger are rejected with 413
+// Bodies without a length stop being read at the limit, so they fail to bind and are rejected with 400
+func limitRequestBodySize(c *gin.Context) {
+
+	bodyLimitMB := maxBodyMB
+	if restoreEndpoints[c.FullPath()] {
+		bodyLimitMB = maxRestoreBodyMB
+	}
+
+	maxBodyBytes := int64(bodyLimitMB) << 20
+	if c.Request.ContentLength > maxBodyBytes {
+		c.AbortWithStatusJSON(413, gin.H{"status": "Request body is too large, it should be at most " + strconv.Itoa(bodyLimitMB) + " MB"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+
+	c.Next()
+
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+
+	_ "modernc.org/sqlite"
+)
+
+// Queries to create the supporting tables used alongside BOOKMANAGEMENT
+// Each query uses IF NOT EXISTS, so running them against an existing DB is safe
+var queriesToCreateTables = []string{
+	`CREATE TABLE IF NOT EXISTS BOOKLOANS(
+		ID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		BOOKID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		BORROWER VARCHAR(100) NOT NULL COLLATE NOCASE,
+		DATELENT INTEGER NOT NULL,
+		DATEDUE INTEGER NOT NULL,
+		DATERETURNED INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE TABLE IF NOT EXISTS BOOKOWNERSHIP(
+		BOOKID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		ROOM VARCHAR(100) NOT NULL DEFAULT '' COLLATE NOCASE,
+		SHELF VARCHAR(100) NOT NULL DEFAULT '' COLLATE NOCASE,
+		BOX VARCHAR(100) NOT NULL DEFAULT '' COLLATE NOCASE,
+		DATEACQUIRED INTEGER NOT NULL DEFAULT 0,
+		SOURCE VARCHAR(100) NOT NULL DEFAULT '' COLLATE NOCASE,
+		PRICEPAID REAL NOT NULL DEFAULT 0,
+		CONDITION VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE
+	);`,
+	`CREATE TABLE IF NOT EXISTS BOOKCOVERS(
+		BOOKID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		FILENAME VARCHAR(100) NOT NULL,
+		THUMBNAILFILENAME VARCHAR(100) NOT NULL,
+		CONTENTTYPE VARCHAR(50) NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS GENRES(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		NAME VARCHAR(100) NOT NULL COLLATE NOCASE,
+		PARENTID VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE
+	);`,
+	`CREATE TABLE IF NOT EXISTS BOOKGENRES(
+		BOOKID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		GENREID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		PRIMARY KEY (BOOKID, GENREID)
+	);`,
+	`CREATE TABLE IF NOT EXISTS BOOKRELATIONS(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		BOOKID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		RELATEDBOOKID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		RELATION VARCHAR(50) NOT NULL COLLATE NOCASE
+	);`,
+	`CREATE TABLE IF NOT EXISTS BOOKADDITIONS(
+		BOOKID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		DATEADDED INTEGER NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS BOOKMETADATA(
+		BOOKID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		ISBN VARCHAR(20) NOT NULL DEFAULT '',
+		PUBLICATIONYEAR INTEGER NOT NULL DEFAULT 0,
+		SUBJECTS TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE TABLE IF NOT EXISTS METADATACACHE(
+		LOOKUP TEXT NOT NULL PRIMARY KEY,
+		RESPONSE TEXT NOT NULL,
+		DATEFETCHED INTEGER NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS USERS(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		USERNAME VARCHAR(100) NOT NULL UNIQUE COLLATE NOCASE,
+		PASSWORDHASH VARCHAR(100) NOT NULL,
+		ISADMIN INTEGER NOT NULL DEFAULT 0,
+		DATECREATED INTEGER NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS APIKEYS(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		USERID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		NAME VARCHAR(100) NOT NULL COLLATE NOCASE,
+		KEYHASH VARCHAR(64) NOT NULL UNIQUE,
+		KEYPREFIX VARCHAR(20) NOT NULL,
+		SCOPES VARCHAR(50) NOT NULL,
+		DATECREATED INTEGER NOT NULL,
+		DATELASTUSED INTEGER NOT NULL DEFAULT 0,
+		DATEREVOKED INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE TABLE IF NOT EXISTS LIBRARYMEMBERS(
+		LIBRARYID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		USERID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		ROLE VARCHAR(10) NOT NULL,
+		DATEADDED INTEGER NOT NULL,
+		PRIMARY KEY (LIBRARYID, USERID)
+	);`,
+	`CREATE TABLE IF NOT EXISTS SHARETOKENS(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		LIBRARYID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		NAME VARCHAR(100) NOT NULL COLLATE NOCASE,
+		TOKENHASH VARCHAR(64) NOT NULL UNIQUE,
+		DATECREATED INTEGER NOT NULL,
+		DATEEXPIRES INTEGER NOT NULL DEFAULT 0,
+		DATEREVOKED INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE TABLE IF NOT EXISTS AUDITLOG(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		LIBRARYID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		USERID VARCHAR(50) NOT NULL COLLATE NOCASE,
+		APIKEYID VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE,
+		DATE INTEGER NOT NULL,
+		METHOD VARCHAR(10) NOT NULL,
+		ENDPOINT VARCHAR(100) NOT NULL,
+		BOOKID VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE,
+		BEFORE TEXT NOT NULL DEFAULT '',
+		AFTER TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE INDEX IF NOT EXISTS AUDITLOGBYLIBRARY ON AUDITLOG(LIBRARYID, DATE);`,
+	`CREATE TABLE IF NOT EXISTS BOOKTRASH(
+		ID VARCHAR(50) NOT NULL PRIMARY KEY COLLATE NOCASE,
+		BOOK VARCHAR(100) NOT NULL COLLATE NOCASE,
+		AUTHOR VARCHAR(100) NOT NULL COLLATE NOCASE,
+		TOTALPAGES INTEGER NOT NULL,
+		READPAGES INTEGER NOT NULL,
+		DATESTARTED INTEGER,
+		DATEFINISHED INTEGER,
+		NOTES TEXT,
+		USERID VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE,
+		DATEDELETED INTEGER NOT NULL
+	);`,
+}
+
+// Columns added to existing tables, each is only added if the table does not have it yet
+// SQLite has no ADD COLUMN IF NOT EXISTS, so the columns are checked first
+var columnsToAddToTables = []struct {
+	Table      string
+	Column     string
+	Definition string
+}{
+	{"BOOKMANAGEMENT", "USERID", "VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE"},
+	{"BOOKMANAGEMENT", "VERSION", "INTEGER NOT NULL DEFAULT 1"},
+	{"BOOKTRASH", "VERSION", "INTEGER NOT NULL DEFAULT 1"},
+	{"GENRES", "USERID", "VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE"},
+}
+
+// Creates the supporting tables in the DB and adds the new columns to the existing tables, if they are not already present
+// Called once from main() before the routes are served
+func createTables() {
+
+	// Connect to the DB. If there is any issue connecting to the DB, stop the server as none of the routes can work
+	db, err := sql.Open("sqlite", "./BOOKMANAGEMENT.db")
+	if err != nil {
+		log.Fatal("Could not connect to DB")
+	}
+	defer db.Close()
+
+	// Run each of the queries, if any of them fail, stop the server
+	for _, queryToCreateTable := range queriesToCreateTables {
+		if _, err = db.Exec(queryToCreateTable); err != nil {
+			log.Fatal("Could not create table, ", err)
+		}
+	}
+
+	// Add each of the columns which are not present, if any of them fail, stop the server
+	for _, columnToAdd := range columnsToAddToTables {
+		var columnCount int
+		db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info($1) WHERE NAME = $2;`, columnToAdd.Table, columnToAdd.Column).Scan(&columnCount)
+		if columnCount > 0 {
+			continue
+		}
+		if _, err = db.Exec(`ALTER TABLE ` + columnToAdd.Table + ` ADD COLUMN ` + columnToAdd.Column + ` ` + columnToAdd.Definition + `;`); err != nil {
+			log.Fatal("Could not add column, ", err)
+		}
+	}
+
+	// Genres were shared by every library before each library had its own, so the first admin keeps the Genres which do not belong to anyone
+	queryToTakeOverGenres := `UPDATE GENRES SET USERID = (SELECT ID FROM USERS WHERE ISADMIN = 1 ORDER BY DATECREATED LIMIT 1)
+	WHERE USERID = '' AND EXISTS (SELECT ID FROM USERS WHERE ISADMIN = 1);`
+	if _, err = db.Exec(queryToTakeOverGenres); err != nil {
+		log.Fatal("Could not update Genres, ", err)
+	}
+
+}